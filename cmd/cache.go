@@ -0,0 +1,170 @@
+/*
+Copyright © 2024 Dmitry Mozzherin <dmozzherin@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/cache"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manages the cache of processed DwCA files.",
+	Long: `Manages the cache of DwCA files. With --cache flag extracted files and
+	normalized output of archives are kept in the cache, and are reused if
+	the same archive is processed again.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		_ = cmd.Help()
+	},
+}
+
+// cacheListCmd represents the cache list command
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Shows archives in the cache.",
+	Long: `Shows checksums, sizes and the time of the last use of archives in
+	the cache, starting from the most recently used.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := []flagFunc{debugFlag, rootDirFlag}
+		for _, v := range flags {
+			v(cmd)
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" {
+			slog.Error("Unknown output format", "format", format)
+			os.Exit(1)
+		}
+
+		cfg := config.New(opts...)
+		es, err := dwca.CacheList(cfg)
+		if err != nil {
+			slog.Error("Cannot read cache", "error", err)
+			os.Exit(1)
+		}
+		printEntries(es, format)
+	},
+}
+
+// cachePruneCmd represents the cache prune command
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Removes old archives from the cache.",
+	Long: `Removes archives that were not used for longer than the maximum
+	age, and least recently used archives until the cache is not bigger than
+	the maximum size. Limits are taken from the flags or from the
+	configuration file. With --all flag the whole cache is removed.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		flags := []flagFunc{debugFlag, rootDirFlag, cacheLimitsFlag}
+		for _, v := range flags {
+			v(cmd)
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" {
+			slog.Error("Unknown output format", "format", format)
+			os.Exit(1)
+		}
+
+		all, _ := cmd.Flags().GetBool("all")
+		if all {
+			// any archive is older than 1ns.
+			opts = append(opts, config.OptCacheMaxAge(time.Nanosecond))
+		}
+
+		cfg := config.New(opts...)
+		if cfg.CacheMaxSize == 0 && cfg.CacheMaxAge == 0 {
+			slog.Warn("Cache limits are not set, nothing to prune")
+			return
+		}
+		es, err := dwca.CachePrune(cfg)
+		if err != nil {
+			slog.Error("Cannot prune cache", "error", err)
+			os.Exit(1)
+		}
+		printEntries(es, format)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+
+	for _, v := range []*cobra.Command{cacheListCmd, cachePruneCmd} {
+		v.Flags().StringP("format", "f", "text",
+			"output format (text or json)",
+		)
+	}
+
+	cachePruneCmd.Flags().String(
+		"max-size", "",
+		"maximum size of the cache, for example '10GB'",
+	)
+	cachePruneCmd.Flags().String(
+		"max-age", "",
+		"maximum time since the last use of an archive, for example '720h'",
+	)
+	cachePruneCmd.Flags().Bool(
+		"all", false,
+		"remove all archives from the cache",
+	)
+}
+
+func printEntries(es []cache.Entry, format string) {
+	if format == "json" {
+		if es == nil {
+			es = []cache.Entry{}
+		}
+		bs, err := json.MarshalIndent(es, "", "  ")
+		if err != nil {
+			slog.Error("Cannot create JSON output", "error", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(bs))
+		return
+	}
+
+	var sb strings.Builder
+	var size int64
+	for _, v := range es {
+		size += v.Size
+		fmt.Fprintf(&sb, "%s  %8s  %s  outputs: %d\n",
+			v.Checksum, humanize.Bytes(uint64(v.Size)),
+			v.LastUsed.Format(time.DateTime), v.OutputsNum,
+		)
+	}
+	fmt.Fprintf(&sb, "Archives: %d, size: %s\n",
+		len(es), humanize.Bytes(uint64(size)))
+	fmt.Print(sb.String())
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2024 Dmitry Mozzherin <dmozzherin@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports DwCA file to a database.",
+	Long: `Exports the Core and all extensions of DwCA file to a database.
+	Every data file becomes a table named after its row type, with columns
+	named after terms of its fields. The 'meta' table describes the columns,
+	the 'eml' table keeps EML data as JSON.
+
+	If output path is not given, the output will be
+	'{input file name}.sqlite'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := []flagFunc{
+			debugFlag, rootDirFlag, fieldsNumFlag, streamFlag, cacheFlag,
+		}
+		for _, v := range flags {
+			v(cmd)
+		}
+		in, out := getInput(cmd, args)
+		if len(args) == 1 {
+			out = in + ".sqlite"
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "sqlite" {
+			slog.Error("Unknown export format", "format", format)
+			os.Exit(1)
+		}
+
+		cfg := config.New(opts...)
+		arc, err := dwca.Factory(in, cfg)
+		if err != nil {
+			slog.Error("Cannot initialize DwCA", "error", err)
+			os.Exit(1)
+		}
+		defer arc.Close()
+
+		err = arc.Load(arc.Config().ExtractPath)
+		if err != nil {
+			slog.Error("Cannot load DwCA", "error", err)
+			closeAndExit(arc, 1)
+		}
+
+		err = arc.ExportSQLite(context.Background(), out)
+		if err != nil {
+			slog.Error("Cannot export DwCA", "error", err)
+			closeAndExit(arc, 1)
+		}
+		slog.Info("DwCA file is exported", "output", out)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringP("format", "f", "sqlite",
+		"format of the output (sqlite)",
+	)
+
+	exportCmd.Flags().StringP(
+		"wrong-fields-num", "w", "",
+		"how to process rows with wrong number of fields\n"+
+			"choices: 'stop', 'skip', 'process'\n"+
+			"default: 'stop'",
+	)
+}
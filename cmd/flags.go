@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	dwca "github.com/gnames/dwca/pkg"
 	"github.com/gnames/dwca/pkg/config"
 	"github.com/gnames/gnfmt"
@@ -68,6 +70,71 @@ func jobsNumFlag(cmd *cobra.Command) {
 	}
 }
 
+func extErrorsFlag(cmd *cobra.Command) {
+	policy, _ := cmd.Flags().GetString("ext-errors")
+	if policy != "" {
+		opts = append(opts, config.OptExtErrorPolicy(policy))
+	}
+}
+
+func diagnFlag(cmd *cobra.Command) {
+	mode, _ := cmd.Flags().GetString("diagn-mode")
+	if mode != "" {
+		opts = append(opts, config.OptDiagnMode(mode))
+	}
+	size, _ := cmd.Flags().GetInt("sample-size")
+	if size > 0 {
+		opts = append(opts, config.OptDiagnSampleSize(size))
+	}
+}
+
+func streamFlag(cmd *cobra.Command) {
+	b, _ := cmd.Flags().GetBool("stream")
+	if b {
+		opts = append(opts, config.OptStreamArchive(true))
+	}
+}
+
+func cacheFlag(cmd *cobra.Command) {
+	b, _ := cmd.Flags().GetBool("cache")
+	if b {
+		opts = append(opts, config.OptUseCache(true))
+	}
+}
+
+func cacheLimitsFlag(cmd *cobra.Command) {
+	size, _ := cmd.Flags().GetString("max-size")
+	if size != "" {
+		opts = appendCacheMaxSize(opts, size)
+	}
+	age, _ := cmd.Flags().GetString("max-age")
+	if age != "" {
+		opts = appendCacheMaxAge(opts, age)
+	}
+}
+
+// appendCacheMaxSize adds the cache size limit given in human-readable
+// form, like "10GB".
+func appendCacheMaxSize(opts []config.Option, s string) []config.Option {
+	size, err := humanize.ParseBytes(s)
+	if err != nil {
+		slog.Warn("Cannot parse cache size, keeping default", "setting", s)
+		return opts
+	}
+	return append(opts, config.OptCacheMaxSize(int64(size)))
+}
+
+// appendCacheMaxAge adds the cache age limit given as a duration, like
+// "720h".
+func appendCacheMaxAge(opts []config.Option, s string) []config.Option {
+	age, err := time.ParseDuration(s)
+	if err != nil {
+		slog.Warn("Cannot parse cache age, keeping default", "setting", s)
+		return opts
+	}
+	return append(opts, config.OptCacheMaxAge(age))
+}
+
 func versionFlag(cmd *cobra.Command) {
 	b, _ := cmd.Flags().GetBool("version")
 	if b {
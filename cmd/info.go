@@ -0,0 +1,220 @@
+/*
+Copyright © 2024 Dmitry Mozzherin <dmozzherin@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/diagn"
+	"github.com/gnames/dwca/pkg/ent/meta"
+	"github.com/spf13/cobra"
+)
+
+// archiveInfo contains summary information about a DwCA file.
+type archiveInfo struct {
+	Title       string             `json:"title"`
+	Diagnostics *diagn.Diagnostics `json:"diagnostics"`
+	Core        fileInfo           `json:"core"`
+	Extensions  []fileInfo         `json:"extensions"`
+}
+
+// fileInfo contains summary information about a data file of DwCA.
+type fileInfo struct {
+	File    string   `json:"file"`
+	RowType string   `json:"rowType"`
+	RowsNum int      `json:"rowsNum"`
+	Fields  []string `json:"fields"`
+}
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Shows information about DwCA file.",
+	Long: `Shows how scientific names, synonyms and hierarchy are represented
+	in DwCA file, together with row types, fields and number of rows of the
+	core and extensions, and the title of the dataset.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := []flagFunc{
+			debugFlag, rootDirFlag, fieldsNumFlag, diagnFlag, streamFlag,
+			cacheFlag,
+		}
+		for _, v := range flags {
+			v(cmd)
+		}
+		if len(args) != 1 {
+			_ = cmd.Help()
+			os.Exit(0)
+		}
+		in := args[0]
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" {
+			slog.Error("Unknown output format", "format", format)
+			os.Exit(1)
+		}
+
+		cfg := config.New(opts...)
+		arc, err := dwca.Factory(in, cfg)
+		if err != nil {
+			slog.Error("Cannot initialize DwCA", "error", err)
+			os.Exit(1)
+		}
+		defer arc.Close()
+
+		err = arc.Load(arc.Config().ExtractPath)
+		if err != nil {
+			slog.Error("Cannot load DwCA", "error", err)
+			closeAndExit(arc, 1)
+		}
+
+		info, err := getInfo(arc)
+		if err != nil {
+			slog.Error("Cannot read DwCA data", "error", err)
+			closeAndExit(arc, 1)
+		}
+
+		if format == "json" {
+			bs, err := json.MarshalIndent(info, "", "  ")
+			if err != nil {
+				slog.Error("Cannot create JSON output", "error", err)
+				closeAndExit(arc, 1)
+			}
+			fmt.Println(string(bs))
+			return
+		}
+		fmt.Print(info.text())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+
+	infoCmd.Flags().StringP("format", "f", "text",
+		"output format (text or json)",
+	)
+
+	infoCmd.Flags().StringP(
+		"wrong-fields-num", "w", "",
+		"how to process rows with wrong number of fields\n"+
+			"choices: 'stop', 'skip', 'process'\n"+
+			"default: 'stop'",
+	)
+}
+
+func getInfo(arc dwca.Archive) (*archiveInfo, error) {
+	m := arc.Meta()
+	res := &archiveInfo{
+		Title:       arc.EML().Dataset.Title,
+		Diagnostics: arc.Diagnostics(),
+		Core:        newFileInfo(m.Core.Attr),
+		Extensions:  make([]fileInfo, len(m.Extensions)),
+	}
+
+	var err error
+	res.Core.RowsNum, err = countRows(func(ch chan []string) (int, error) {
+		return arc.CoreStream(context.Background(), ch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range m.Extensions {
+		res.Extensions[i] = newFileInfo(v.Attr)
+		res.Extensions[i].RowsNum, err = countRows(
+			func(ch chan []string) (int, error) {
+				return arc.ExtensionStream(context.Background(), i, ch)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func newFileInfo(attr *meta.Attr) fileInfo {
+	res := fileInfo{
+		File:    attr.Files.Location,
+		RowType: attr.RowType,
+		Fields:  make([]string, len(attr.Fields)),
+	}
+	for i, v := range attr.Fields {
+		res.Fields[i] = v.Term
+	}
+	return res
+}
+
+// countRows drains a data stream and returns the number of its rows.
+func countRows(stream func(chan []string) (int, error)) (int, error) {
+	ch := make(chan []string)
+	go func() {
+		for range ch {
+		}
+	}()
+	return stream(ch)
+}
+
+func (ai *archiveInfo) text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Title: %s\n\n", ai.Title)
+	if d := ai.Diagnostics; d != nil {
+		sb.WriteString("Diagnostics:\n")
+		fmt.Fprintf(&sb, "  Rows examined:    %d\n", d.RowsNum)
+		fmt.Fprintf(&sb, "  Scientific names: %s (confidence %.2f)\n",
+			d.SciNameType, d.SciNameConfidence)
+		fmt.Fprintf(&sb, "  Synonyms:         %s (confidence %.2f)\n",
+			d.SynonymType, d.SynonymConfidence)
+		fmt.Fprintf(&sb, "  Hierarchy:        %s (confidence %.2f)\n",
+			d.HierType, d.HierConfidence)
+		sb.WriteString("  Fill rates:\n")
+		for _, k := range slices.Sorted(maps.Keys(d.FillRates)) {
+			fmt.Fprintf(&sb, "    %s: %.2f\n", k, d.FillRates[k])
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Core:\n")
+	ai.Core.write(&sb)
+	for _, v := range ai.Extensions {
+		sb.WriteString("\nExtension:\n")
+		v.write(&sb)
+	}
+	return sb.String()
+}
+
+func (fi fileInfo) write(sb *strings.Builder) {
+	fmt.Fprintf(sb, "  File:     %s\n", fi.File)
+	fmt.Fprintf(sb, "  Row type: %s\n", fi.RowType)
+	fmt.Fprintf(sb, "  Rows:     %d\n", fi.RowsNum)
+	sb.WriteString("  Fields:\n")
+	for _, v := range fi.Fields {
+		fmt.Fprintf(sb, "    %s\n", v)
+	}
+}
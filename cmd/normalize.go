@@ -22,6 +22,7 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"log/slog"
 	"os"
 
@@ -40,6 +41,7 @@ var normalizeCmd = &cobra.Command{
 		var err error
 		flags := []flagFunc{
 			debugFlag, rootDirFlag, jobsNumFlag, archiveFlag, csvFlag, fieldsNumFlag,
+			diagnFlag, streamFlag, cacheFlag, extErrorsFlag,
 		}
 		for _, v := range flags {
 			v(cmd)
@@ -52,6 +54,7 @@ var normalizeCmd = &cobra.Command{
 			slog.Error("Cannot initialize DwCA", "error", err)
 			os.Exit(1)
 		}
+		defer arc.Close()
 
 		slog.Info(
 			"Configuration",
@@ -59,16 +62,23 @@ var normalizeCmd = &cobra.Command{
 			"output_csv_type", cfg.OutputCSVType,
 			"archive_type", cfg.OutputArchiveCompression)
 
-		err = arc.Load(cfg.ExtractPath)
+		err = arc.Load(arc.Config().ExtractPath)
 		if err != nil {
 			slog.Error("Cannot load DwCA", "error", err)
-			os.Exit(1)
+			closeAndExit(arc, 1)
 		}
 
+		// with "continue" policy failed extensions are reported, but the
+		// normalized archive is still created.
+		var extErr *dwca.ErrExtension
 		err = arc.Normalize()
 		if err != nil {
-			slog.Error("Cannot normalize DwCA", "error", err)
-			os.Exit(1)
+			if !errors.As(err, &extErr) ||
+				arc.Config().ExtErrorPolicy != "continue" {
+				slog.Error("Cannot normalize DwCA", "error", err)
+				closeAndExit(arc, 1)
+			}
+			slog.Error("Some extensions are excluded from output", "error", err)
 		}
 
 		if arc.Config().OutputArchiveCompression == "zip" {
@@ -80,9 +90,13 @@ var normalizeCmd = &cobra.Command{
 		}
 		if err != nil {
 			slog.Error("Cannot archive DwCA data", "error", err)
-			os.Exit(1)
+			closeAndExit(arc, 1)
 		}
 
+		if extErr != nil {
+			slog.Warn("DwCA normalized with errors", "input", in, "output", out)
+			closeAndExit(arc, 1)
+		}
 		slog.Info("DwCA normalized", "input", in, "output", out)
 	},
 }
@@ -109,6 +123,13 @@ func init() {
 			"default: 'stop'",
 	)
 
+	normalizeCmd.Flags().StringP(
+		"ext-errors", "e", "",
+		"what to do if an extension cannot be processed\n"+
+			"choices: 'abort', 'continue'\n"+
+			"default: 'abort'",
+	)
+
 	normalizeCmd.Flags().StringP("csv-type", "c", "",
 		"type of CSV files in the output archive (csv or tsv)",
 	)
@@ -28,6 +28,7 @@ import (
 	"os"
 	"path/filepath"
 
+	dwca "github.com/gnames/dwca/pkg"
 	"github.com/gnames/dwca/pkg/config"
 	"github.com/gnames/gnsys"
 	"github.com/spf13/cobra"
@@ -42,6 +43,13 @@ type configData struct {
 	OutputArchiveCompression string
 	OutputCSVType            string
 	JobsNum                  int
+	DiagnMode                string
+	DiagnSampleSize          int
+	ExtErrorPolicy           string
+	StreamArchive            bool
+	UseCache                 bool
+	CacheMaxSize             string
+	CacheMaxAge              string
 }
 
 var opts []config.Option
@@ -89,6 +97,29 @@ func init() {
 		"root path for the DwCA file",
 	)
 
+	rootCmd.PersistentFlags().StringP(
+		"diagn-mode", "m", "",
+		"rows used to detect names, synonyms and hierarchy representation\n"+
+			"choices: 'head', 'stratified', 'full'\n"+
+			"default: 'head'",
+	)
+
+	rootCmd.PersistentFlags().IntP(
+		"sample-size", "s", 0,
+		"number of rows used to detect names, synonyms and hierarchy\n"+
+			"representation (default 1000)",
+	)
+
+	rootCmd.PersistentFlags().Bool(
+		"stream", false,
+		"read data directly from ZIP or TAR archive without extraction",
+	)
+
+	rootCmd.PersistentFlags().Bool(
+		"cache", false,
+		"reuse extracted files and normalized output of unchanged archives",
+	)
+
 	rootCmd.PersistentFlags().BoolP(
 		"debug", "d", false,
 		"debug mode",
@@ -119,6 +150,13 @@ func initConfig() {
 	_ = viper.BindEnv("OutputArchiveCompression", "DWCA_OUTPUT_ARCHIVE_COMPRESSION")
 	_ = viper.BindEnv("OutputCSVType", "DWCA_OUTPUT_CSV_TYPE")
 	_ = viper.BindEnv("JobsNum", "DWCA_JOBS_NUM")
+	_ = viper.BindEnv("DiagnMode", "DWCA_DIAGN_MODE")
+	_ = viper.BindEnv("DiagnSampleSize", "DWCA_DIAGN_SAMPLE_SIZE")
+	_ = viper.BindEnv("ExtErrorPolicy", "DWCA_EXT_ERROR_POLICY")
+	_ = viper.BindEnv("StreamArchive", "DWCA_STREAM_ARCHIVE")
+	_ = viper.BindEnv("UseCache", "DWCA_USE_CACHE")
+	_ = viper.BindEnv("CacheMaxSize", "DWCA_CACHE_MAX_SIZE")
+	_ = viper.BindEnv("CacheMaxAge", "DWCA_CACHE_MAX_AGE")
 
 	viper.AutomaticEnv() // read in environment variables that match
 
@@ -145,6 +183,9 @@ func getOpts() {
 		os.Exit(1)
 	}
 
+	// several runs of dwca can use the same RootPath.
+	opts = append(opts, config.OptIsolateWorkDir(true))
+
 	if cfgCli.RootPath != "" {
 		opts = append(opts, config.OptRootPath(cfgCli.RootPath))
 	}
@@ -166,6 +207,34 @@ func getOpts() {
 	if cfgCli.JobsNum != 0 {
 		opts = append(opts, config.OptJobsNum(cfgCli.JobsNum))
 	}
+
+	if cfgCli.DiagnMode != "" {
+		opts = append(opts, config.OptDiagnMode(cfgCli.DiagnMode))
+	}
+
+	if cfgCli.DiagnSampleSize != 0 {
+		opts = append(opts, config.OptDiagnSampleSize(cfgCli.DiagnSampleSize))
+	}
+
+	if cfgCli.ExtErrorPolicy != "" {
+		opts = append(opts, config.OptExtErrorPolicy(cfgCli.ExtErrorPolicy))
+	}
+
+	if cfgCli.StreamArchive {
+		opts = append(opts, config.OptStreamArchive(true))
+	}
+
+	if cfgCli.UseCache {
+		opts = append(opts, config.OptUseCache(true))
+	}
+
+	if cfgCli.CacheMaxSize != "" {
+		opts = appendCacheMaxSize(opts, cfgCli.CacheMaxSize)
+	}
+
+	if cfgCli.CacheMaxAge != "" {
+		opts = appendCacheMaxAge(opts, cfgCli.CacheMaxAge)
+	}
 }
 
 // touchConfigFile checks if config file exists, and if not, it gets created.roo
@@ -197,3 +266,11 @@ func createConfig(path string) {
 		os.Exit(1)
 	}
 }
+
+// closeAndExit removes temporary files of the archive and exits with the
+// given status code. Deferred calls do not run after os.Exit, so the
+// archive has to be closed explicitly.
+func closeAndExit(arc dwca.Archive, code int) {
+	_ = arc.Close()
+	os.Exit(code)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright © 2024 Dmitry Mozzherin <dmozzherin@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/gnfmt"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks DwCA file for problems.",
+	Long: `Checks DwCA file for problems like missing files, duplicate IDs,
+	broken references between records, cycles in the hierarchy and empty
+	scientific names. Exits with status 1 if problems are found.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// rows with wrong number of fields are processed by default,
+		// so they do not stop validation.
+		opts = append(opts, config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+		flags := []flagFunc{
+			debugFlag, rootDirFlag, jobsNumFlag, fieldsNumFlag, diagnFlag,
+			streamFlag, cacheFlag,
+		}
+		for _, v := range flags {
+			v(cmd)
+		}
+		if len(args) != 1 {
+			_ = cmd.Help()
+			os.Exit(0)
+		}
+		in := args[0]
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" {
+			slog.Error("Unknown report format", "format", format)
+			os.Exit(1)
+		}
+
+		cfg := config.New(opts...)
+		arc, err := dwca.Factory(in, cfg)
+		if err != nil {
+			slog.Error("Cannot initialize DwCA", "error", err)
+			os.Exit(1)
+		}
+		defer arc.Close()
+
+		err = arc.Load(arc.Config().ExtractPath)
+		if err != nil {
+			slog.Error("Cannot load DwCA", "error", err)
+			closeAndExit(arc, 1)
+		}
+
+		res, err := arc.Validate(context.Background())
+		if err != nil {
+			slog.Error("Cannot validate DwCA", "error", err)
+			closeAndExit(arc, 1)
+		}
+
+		if format == "json" {
+			bs, err := res.JSON()
+			if err != nil {
+				slog.Error("Cannot create JSON report", "error", err)
+				closeAndExit(arc, 1)
+			}
+			fmt.Println(string(bs))
+		} else {
+			fmt.Print(res.Text())
+		}
+
+		if !res.Valid {
+			closeAndExit(arc, 1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().StringP("format", "f", "text",
+		"format of the report (text or json)",
+	)
+
+	validateCmd.Flags().StringP(
+		"wrong-fields-num", "w", "",
+		"how to process rows with wrong number of fields\n"+
+			"choices: 'stop', 'skip', 'process'\n"+
+			"default: 'process'",
+	)
+}
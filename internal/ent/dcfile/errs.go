@@ -67,6 +67,20 @@ func (e *ErrCoreRead) Error() string {
 	return fmt.Sprintf("reading core file failed: %v", e.Err)
 }
 
+// ErrIndex is returned when the index of data files cannot be created or
+// opened.
+type ErrIndex struct {
+	Err error
+}
+
+func (e *ErrIndex) Error() string {
+	return fmt.Sprintf("cannot index data files: %v", e.Err)
+}
+
+func (e *ErrIndex) Unwrap() error {
+	return e.Err
+}
+
 type ErrExtensionRead struct {
 	Err error
 }
@@ -14,6 +14,8 @@ const (
 	TARGZ            // .tar.gz
 	TARXZ            // .tar.xz
 	TARBZ2           //.tar.bz2
+	DIR              // directory with unpacked archive
+	FS               // fs.FS with unpacked archive
 )
 
 var ftMap = map[FileType]string{
@@ -23,6 +25,8 @@ var ftMap = map[FileType]string{
 	TARGZ:   "tar-gzip",
 	TARXZ:   "tar-xz",
 	TARBZ2:  "tar-bz2",
+	DIR:     "directory",
+	FS:      "fs",
 }
 
 func (ft FileType) String() string {
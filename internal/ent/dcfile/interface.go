@@ -2,6 +2,7 @@ package dcfile
 
 import (
 	"context"
+	"io/fs"
 
 	"github.com/gnames/dwca/pkg/ent/meta"
 )
@@ -23,6 +24,12 @@ type DCFile interface {
 	// where DwCA data is located.
 	ArchiveDir(path string) (string, error)
 
+	// FS returns the file system with DwCA files and the slash-separated
+	// directory inside of it where meta.xml is located. The root is the
+	// directory where the archive was extracted, or the directory with
+	// normalized output.
+	FS(root string) (fs.FS, string, error)
+
 	// CoreData returns the content of the core file as a slice of slices of
 	// strings. Each slice of strings represents a row in the core file.
 	CoreData(root string, meta *meta.Meta, offset, limit int) ([][]string, error)
@@ -60,6 +67,53 @@ type DCFile interface {
 		extChan chan<- []string,
 	) (int, error)
 
+	// SortedCoreStream populates a channel with rows of the core file
+	// sorted by their IDs. Rows with the same ID keep their order. Sorting
+	// uses temporary files, so the core can be larger than memory. It
+	// returns the number of rows.
+	SortedCoreStream(
+		ctx context.Context,
+		root string,
+		meta *meta.Meta,
+		ch chan<- Row,
+	) (int, error)
+
+	// SortedExtensionStream populates a channel with rows of the extension
+	// with the given index sorted by their core IDs. Rows with the same
+	// core ID keep their order. Sorting uses temporary files, so the
+	// extension can be larger than memory. It returns the number of rows.
+	SortedExtensionStream(
+		ctx context.Context,
+		index int, root string,
+		meta *meta.Meta,
+		ch chan<- Row,
+	) (int, error)
+
+	// Index creates an index of rows of the core and extension files, or
+	// opens the index that was created before for the same data. When the
+	// index exists, CoreData and ExtensionData read rows starting from
+	// their positions in files instead of scanning files from the start.
+	Index(root string, meta *meta.Meta) error
+
+	// CoreByID returns rows of the core file with the given ID. The index
+	// is created if it does not exist yet.
+	CoreByID(root string, meta *meta.Meta, id string) ([]Row, error)
+
+	// ExtensionByCoreID returns rows of the extension with the given index
+	// that refer to the given core ID. The index is created if it does not
+	// exist yet.
+	ExtensionByCoreID(
+		index int, root string,
+		meta *meta.Meta,
+		coreID string,
+	) ([]Row, error)
+
+	// FieldsNum returns the number of fields in rows of data files of a
+	// Core or an Extension. If there are several files, the largest number
+	// is returned. The number is taken from the header, or from the first
+	// row if there is no header.
+	FieldsNum(root string, attr *meta.Attr) (int, error)
+
 	// ExportCSVStream saves the content of a stream to a file. The file is a
 	// comma-separated file with the first row being the header. The header is
 	// defined by the fields parameter. This function is used to export Core or
@@ -71,6 +125,19 @@ type DCFile interface {
 		delim string,
 		outChan <-chan []string) error
 
+	// RemoveOutput removes a file from the output directory. It does not
+	// return an error if the file does not exist.
+	RemoveOutput(file string) error
+
+	// CachedOutput copies normalized output that was saved in the cache
+	// with the given key to the output directory. It returns false if
+	// there is no such output, or if the cache is not used.
+	CachedOutput(key string) (bool, error)
+
+	// CacheOutput saves normalized output to the cache with the given key.
+	// The key should reflect settings that change the output.
+	CacheOutput(key string) error
+
 	// SaveToFile saves bytes slice to a file with the provided name.
 	SaveToFile(fileName string, bs []byte) error
 
@@ -0,0 +1,11 @@
+package dcfile
+
+// Row is a row of a data file together with its number.
+type Row struct {
+	// RowNum is the number of the row among data rows, counted from 1.
+	// Header lines and skipped bad rows are not counted.
+	RowNum int
+
+	// Values are the fields of the row.
+	Values []string
+}
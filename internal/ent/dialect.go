@@ -0,0 +1,58 @@
+package ent
+
+import (
+	"strconv"
+	"strings"
+)
+
+// HeaderLines returns the number of header lines that have to be skipped
+// according to IgnoreHeader.
+func (a CSVAttr) HeaderLines() int {
+	res, err := strconv.Atoi(strings.TrimSpace(a.IgnoreHeader))
+	if err != nil || res < 0 {
+		return 0
+	}
+	return res
+}
+
+// IsLF returns true if lines are terminated by "\n" or "\r\n".
+func (a CSVAttr) IsLF() bool {
+	return a.LineSep == "" || a.LineSep == "\n" || a.LineSep == "\r\n"
+}
+
+// Unescape converts escape sequences used in meta.xml attributes, like
+// `\t` or `\r\n`, to the characters they represent.
+func Unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var res strings.Builder
+	var escaped bool
+	for _, r := range s {
+		if !escaped {
+			if r == '\\' {
+				escaped = true
+				continue
+			}
+			res.WriteRune(r)
+			continue
+		}
+		escaped = false
+		switch r {
+		case 't':
+			res.WriteRune('\t')
+		case 'n':
+			res.WriteRune('\n')
+		case 'r':
+			res.WriteRune('\r')
+		case 'f':
+			res.WriteRune('\f')
+		default:
+			res.WriteRune(r)
+		}
+	}
+	if escaped {
+		res.WriteRune('\\')
+	}
+	return res.String()
+}
@@ -0,0 +1,43 @@
+package ent_test
+
+import (
+	"testing"
+
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnescape(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		msg, in, out string
+	}{
+		{"empty", "", ""},
+		{"comma", ",", ","},
+		{"tab", `\t`, "\t"},
+		{"real tab", "\t", "\t"},
+		{"crlf", `\r\n`, "\r\n"},
+		{"cr", `\r`, "\r"},
+		{"quote", `\"`, `"`},
+		{"single quote", `'`, `'`},
+		{"backslash", `\\`, `\`},
+		{"trailing", `a\`, `a\`},
+	}
+	for _, v := range tests {
+		assert.Equal(v.out, ent.Unescape(v.in), v.msg)
+	}
+}
+
+func TestHeaderLines(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		in  string
+		out int
+	}{
+		{"", 0}, {"0", 0}, {"1", 1}, {" 3 ", 3}, {"-1", 0}, {"yes", 0},
+	}
+	for _, v := range tests {
+		attr := ent.CSVAttr{IgnoreHeader: v.in}
+		assert.Equal(v.out, attr.HeaderLines(), v.in)
+	}
+}
@@ -2,6 +2,9 @@ package ent
 
 import (
 	"context"
+	"io"
+	"io/fs"
+	"os"
 
 	"github.com/gnames/gnfmt"
 )
@@ -16,12 +19,25 @@ type CSVAttr struct {
 	// Path is the path to the CSV file.
 	Path string
 
+	// FS is the file system where the CSV file is located. If it is nil,
+	// the file is read from the operating system's file system, otherwise
+	// Path is a slash-separated path inside FS.
+	FS fs.FS
+
+	// Encoding is the character encoding of the CSV file. Readers convert
+	// data to UTF-8. Empty value means UTF-8.
+	Encoding string
+
 	// ColSep is the UTF-8 character used to separate fields from each other.
 	ColSep rune
 
 	// Quote (usually `"`) that escapes ColSep characters withing the fields.
 	Quote string
 
+	// LineSep is the string that terminates lines. Empty value means "\n".
+	// If it is "\n" or "\r\n", both of these terminators are recognized.
+	LineSep string
+
 	// IgnoreHeader indicates if there is a header row in the CSV file.
 	// If header exists, its values will be ignored.
 	IgnoreHeader string
@@ -31,11 +47,59 @@ type CSVAttr struct {
 	// ignored, of break the execution of the program. Default is to raise an
 	// error.
 	BadRowProcessing gnfmt.BadRow
+
+	// Defaults maps indices of fields to their default values. Empty
+	// fields get default values, fields beyond the end of a row are added
+	// to the row.
+	Defaults map[int]string
+
+	// Offset is the position in the UTF-8 text of the file where reading
+	// starts. If it is not 0, header lines are not skipped, and the number
+	// of fields is taken from FieldsNum.
+	Offset int64
+
+	// FieldsNum is the number of fields in rows when reading starts at
+	// Offset. If it is 0, the number is taken from the first row.
+	FieldsNum int
+}
+
+// Open opens the CSV file for reading.
+func (a CSVAttr) Open() (io.ReadCloser, error) {
+	if a.FS != nil {
+		return a.FS.Open(a.Path)
+	}
+	return os.Open(a.Path)
+}
+
+// SetDefaults adds default values to a row.
+func (a CSVAttr) SetDefaults(row []string) []string {
+	for idx, v := range a.Defaults {
+		if idx >= len(row) {
+			row = append(row, make([]string, idx-len(row)+1)...)
+		}
+		if row[idx] == "" {
+			row[idx] = v
+		}
+	}
+	return row
+}
+
+// OffsetRow is a row of a CSV file together with the position in the
+// UTF-8 text of the file where the row starts.
+type OffsetRow struct {
+	Offset int64
+	Values []string
 }
 
 type CSVReader interface {
 	ReadSlice(offset, limit int) ([][]string, error)
 	Read(context.Context, chan<- []string) (int, error)
+	// ReadOffsets works like Read, but also provides positions of rows,
+	// that can be used as CSVAttr.Offset.
+	ReadOffsets(context.Context, chan<- OffsetRow) (int, error)
+	// FieldsNum returns the number of fields that rows are expected to
+	// have. It is known after header lines or the first row are read.
+	FieldsNum() int
 	Close() error
 }
 
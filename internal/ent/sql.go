@@ -0,0 +1,33 @@
+package ent
+
+import "context"
+
+// SQLTable describes a table of an SQL database.
+type SQLTable struct {
+	// Name is the name of the table.
+	Name string
+
+	// Columns contains names of the table columns. All columns keep text
+	// values.
+	Columns []string
+
+	// Indices contains names of columns that get indices.
+	Indices []string
+}
+
+// SQLWriter saves data to an SQL database.
+type SQLWriter interface {
+	// CreateTable creates an empty table.
+	CreateTable(SQLTable) error
+
+	// Write saves rows from the channel to the table. Rows that are shorter
+	// than the number of columns get empty values, extra values of longer
+	// rows are ignored. It returns the number of saved rows.
+	Write(context.Context, SQLTable, <-chan []string) (int, error)
+
+	// CreateIndices creates indices for the table columns.
+	CreateIndices(SQLTable) error
+
+	// Close closes the database.
+	Close() error
+}
@@ -9,40 +9,67 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/dustin/go-humanize"
 	"github.com/gnames/dwca/internal/ent"
 	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/gnames/dwca/internal/io/encio"
 	"github.com/gnames/gnfmt"
 )
 
 type csvnio struct {
 	a ent.CSVAttr
-	f *os.File
-	r *csv.Reader
+	f io.ReadCloser
+	r recordReader
+
+	// fieldsNum is the number of fields expected in rows.
+	fieldsNum int
 }
 
 func New(attr ent.CSVAttr) (ent.CSVReader, error) {
 	res := &csvnio{a: attr}
 
-	f, err := os.Open(res.a.Path)
+	f, err := res.a.Open()
 	if err != nil {
 		return nil, err
 	}
 	res.f = f
 
-	r := csv.NewReader(f)
-	r.Comma = res.a.ColSep
+	in, err := encio.NewReaderAt(f, res.a.Encoding, res.a.Offset)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	quote, _ := utf8.DecodeRuneInString(res.a.Quote)
+	if quote == '"' && res.a.IsLF() {
+		r := csv.NewReader(in)
+		r.Comma = res.a.ColSep
+		res.r = r
+	} else {
+		res.r = newDialectReader(in, res.a.ColSep, quote, res.a.LineSep)
+	}
 
 	// allow variable number of fields
 	if res.a.BadRowProcessing != gnfmt.ErrorBadRow {
-		r.FieldsPerRecord = -1
+		res.setFieldsPerRecord(-1)
 	}
-	res.r = r
 
 	return res, nil
 }
 
+// setFieldsPerRecord sets the number of fields required in every record.
+// Negative number allows any number of fields, 0 sets the number from
+// the next record.
+func (c *csvnio) setFieldsPerRecord(n int) {
+	switch r := c.r.(type) {
+	case *csv.Reader:
+		r.FieldsPerRecord = n
+	case *dialectReader:
+		r.fieldsPerRecord = n
+	}
+}
+
 func NewWriter(attr ent.CSVAttr) (ent.CSVWriter, error) {
 	res := &csvnio{a: attr}
 	return res, nil
@@ -77,9 +104,6 @@ func (c *csvnio) ReadSlice(offset, limit int) ([][]string, error) {
 			return nil, err
 		}
 
-		if offset > 0 && count <= offset {
-			continue
-		}
 		rowFieldsNum := len(row)
 		if fieldsNum == 0 {
 			fieldsNum = rowFieldsNum
@@ -95,16 +119,38 @@ func (c *csvnio) ReadSlice(offset, limit int) ([][]string, error) {
 			}
 		}
 
+		// skipped rows are not counted, the same way as in read.
 		count++
-		res = append(res, row)
+		if offset > 0 && count <= offset {
+			continue
+		}
+
+		res = append(res, c.a.SetDefaults(row))
 	}
 	return res, nil
 }
 
 func (c *csvnio) skipHeader() (int, int, error) {
+	if c.a.Offset > 0 {
+		if c.a.BadRowProcessing == gnfmt.ErrorBadRow {
+			c.setFieldsPerRecord(c.a.FieldsNum)
+		}
+		return c.a.FieldsNum, 0, nil
+	}
+
 	var fieldsNum, lineNum int
-	// ignore headers gif they are given
-	if c.a.IgnoreHeader == "1" {
+	headerLines := c.a.HeaderLines()
+	if headerLines == 0 {
+		return 0, 0, nil
+	}
+
+	// header lines might have different number of fields, the number of
+	// fields is taken from the last header line.
+	strict := c.a.BadRowProcessing == gnfmt.ErrorBadRow
+	if strict {
+		c.setFieldsPerRecord(-1)
+	}
+	for range headerLines {
 		lineNum++
 		row, err := c.r.Read()
 		if err != nil {
@@ -112,6 +158,9 @@ func (c *csvnio) skipHeader() (int, int, error) {
 		}
 		fieldsNum = len(row)
 	}
+	if strict {
+		c.setFieldsPerRecord(fieldsNum)
+	}
 	return fieldsNum, lineNum, nil
 }
 
@@ -141,6 +190,28 @@ func (c *csvnio) badRow(
 func (c *csvnio) Read(
 	ctx context.Context,
 	ch chan<- []string,
+) (int, error) {
+	return c.read(ctx, func(_ int64, row []string) {
+		ch <- row
+	})
+}
+
+func (c *csvnio) ReadOffsets(
+	ctx context.Context,
+	ch chan<- ent.OffsetRow,
+) (int, error) {
+	return c.read(ctx, func(offset int64, row []string) {
+		ch <- ent.OffsetRow{Offset: offset, Values: row}
+	})
+}
+
+func (c *csvnio) FieldsNum() int {
+	return c.fieldsNum
+}
+
+func (c *csvnio) read(
+	ctx context.Context,
+	send func(int64, []string),
 ) (int, error) {
 	// ignore headers if they are given
 	fieldsNum, lineNum, err := c.skipHeader()
@@ -151,6 +222,7 @@ func (c *csvnio) Read(
 	var count int64
 	for {
 		lineNum++
+		offset := c.a.Offset + c.r.InputOffset()
 		row, err := c.r.Read()
 		if err == io.EOF {
 			break
@@ -164,6 +236,7 @@ func (c *csvnio) Read(
 		if fieldsNum == 0 {
 			fieldsNum = rowFieldsNum
 		}
+		c.fieldsNum = fieldsNum
 
 		if fieldsNum != rowFieldsNum {
 			skip := c.badRow(lineNum, fieldsNum, rowFieldsNum)
@@ -176,23 +249,27 @@ func (c *csvnio) Read(
 
 		count++
 		if count%100_000 == 0 {
-			fmt.Printf("\r%s", strings.Repeat(" ", 50))
-			fmt.Printf("\rProcessed %s lines", humanize.Comma(count))
+			fmt.Fprintf(os.Stderr, "\r%s", strings.Repeat(" ", 50))
+			fmt.Fprintf(os.Stderr, "\rProcessed %s lines", humanize.Comma(count))
 		}
 
 		select {
 		case <-ctx.Done():
 			return 0, &dcfile.ErrContext{Err: ctx.Err()}
 		default:
-			ch <- row
+			send(offset, c.a.SetDefaults(row))
 		}
 	}
 
-	fmt.Printf("\r%s\r", strings.Repeat(" ", 50))
+	fmt.Fprintf(os.Stderr, "\r%s\r", strings.Repeat(" ", 50))
 	return int(count), nil
 }
 
 func (c *csvnio) Close() error {
+	// writers do not keep an open file.
+	if c.f == nil {
+		return nil
+	}
 	return c.f.Close()
 }
 
@@ -0,0 +1,211 @@
+package csvnio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// recordReader reads one CSV record at a time.
+type recordReader interface {
+	Read() ([]string, error)
+	// InputOffset returns the position in the input after the last read
+	// record.
+	InputOffset() int64
+}
+
+// errFieldCount is returned by dialectReader if a record has a wrong
+// number of fields.
+var errFieldCount = errors.New("wrong number of fields")
+
+// dialectReader reads CSV data with any quote character and any line
+// terminator. Quotes inside quoted fields are escaped by doubling them or
+// by a backslash. Outside of quotes a backslash escapes the field
+// separator, the quote and the backslash itself.
+type dialectReader struct {
+	r       *bufio.Reader
+	sep     rune
+	quote   rune
+	lineSep string
+
+	// fieldsPerRecord has the same meaning as in encoding/csv: if it is 0,
+	// it is set to the number of fields of the first record, and all
+	// following records must have the same number of fields. Negative
+	// value allows any number of fields.
+	fieldsPerRecord int
+
+	line int
+
+	// offset is the number of bytes consumed from the input.
+	offset int64
+	// lastSize is the size of the last read rune.
+	lastSize int
+}
+
+func newDialectReader(r io.Reader, sep, quote rune, lineSep string) *dialectReader {
+	if lineSep == "" || lineSep == "\r\n" {
+		lineSep = "\n"
+	}
+	return &dialectReader{
+		r:       bufio.NewReader(r),
+		sep:     sep,
+		quote:   quote,
+		lineSep: lineSep,
+	}
+}
+
+// Read returns the next record. Empty lines are skipped.
+func (d *dialectReader) Read() ([]string, error) {
+	for {
+		d.line++
+		row, err := d.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if len(row) == 1 && row[0] == "" {
+			continue
+		}
+		if d.fieldsPerRecord == 0 {
+			d.fieldsPerRecord = len(row)
+		}
+		if d.fieldsPerRecord > 0 && len(row) != d.fieldsPerRecord {
+			return row, fmt.Errorf("record on line %d: %w", d.line, errFieldCount)
+		}
+		return row, nil
+	}
+}
+
+// InputOffset returns the position in the input after the last read
+// record.
+func (d *dialectReader) InputOffset() int64 {
+	return d.offset
+}
+
+func (d *dialectReader) readRecord() ([]string, error) {
+	var row []string
+	var field strings.Builder
+	var started, inQuote bool
+	for {
+		r, _, err := d.readRune()
+		if err == io.EOF {
+			if !started {
+				return nil, io.EOF
+			}
+			if inQuote {
+				return nil, fmt.Errorf("line %d: extraneous or missing quote", d.line)
+			}
+			return append(row, field.String()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		started = true
+
+		if inQuote {
+			switch r {
+			case d.quote:
+				if d.peekRune() == d.quote {
+					d.readRune()
+					field.WriteRune(d.quote)
+					continue
+				}
+				inQuote = false
+			case '\\':
+				if d.peekRune() == d.quote {
+					d.readRune()
+					field.WriteRune(d.quote)
+					continue
+				}
+				field.WriteRune(r)
+			case '\n':
+				d.line++
+				field.WriteRune(r)
+			default:
+				field.WriteRune(r)
+			}
+			continue
+		}
+
+		switch {
+		case r == d.quote && field.Len() == 0:
+			inQuote = true
+		case r == '\\':
+			next := d.peekRune()
+			if next == d.sep || next == d.quote || next == '\\' {
+				d.readRune()
+				field.WriteRune(next)
+				continue
+			}
+			field.WriteRune(r)
+		case r == d.sep:
+			row = append(row, field.String())
+			field.Reset()
+		case d.isLineEnd(r):
+			return append(row, field.String()), nil
+		default:
+			field.WriteRune(r)
+		}
+	}
+}
+
+// isLineEnd checks if r starts the line terminator, and consumes the rest
+// of the terminator. If the terminator is "\n", "\r\n" is also accepted.
+func (d *dialectReader) isLineEnd(r rune) bool {
+	if d.lineSep == "\n" && r == '\r' {
+		if d.peekRune() == '\n' {
+			d.readRune()
+			return true
+		}
+		return false
+	}
+
+	if !strings.HasPrefix(d.lineSep, string(r)) {
+		return false
+	}
+	rest := d.lineSep[len(string(r)):]
+	if rest == "" {
+		return true
+	}
+	bs, err := d.r.Peek(len(rest))
+	if err != nil || string(bs) != rest {
+		return false
+	}
+	d.discard(len(rest))
+	return true
+}
+
+// peekRune returns the next rune without consuming it, or -1 if there
+// is no next rune.
+func (d *dialectReader) peekRune() rune {
+	r, _, err := d.readRune()
+	if err != nil {
+		return -1
+	}
+	d.unreadRune()
+	return r
+}
+
+// readRune reads the next rune and keeps track of the input offset.
+func (d *dialectReader) readRune() (rune, int, error) {
+	r, size, err := d.r.ReadRune()
+	d.offset += int64(size)
+	d.lastSize = size
+	return r, size, err
+}
+
+// unreadRune unreads the last rune read by readRune.
+func (d *dialectReader) unreadRune() {
+	if d.r.UnreadRune() == nil {
+		d.offset -= int64(d.lastSize)
+		d.lastSize = 0
+	}
+}
+
+// discard skips n bytes of the input.
+func (d *dialectReader) discard(n int) {
+	discarded, _ := d.r.Discard(n)
+	d.offset += int64(discarded)
+	d.lastSize = 0
+}
@@ -0,0 +1,59 @@
+package csvnio
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialectReader(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		msg, in, lineSep string
+		sep, quote       rune
+		out              [][]string
+	}{
+		{"lf", "a,b\nc,d\n", "\n", ',', '"', [][]string{{"a", "b"}, {"c", "d"}}},
+		{"crlf", "a,b\r\nc,d", "\n", ',', '"', [][]string{{"a", "b"}, {"c", "d"}}},
+		{"cr", "a,b\rc,d\r", "\r", ',', '"', [][]string{{"a", "b"}, {"c", "d"}}},
+		{"custom", "a,b||c,d", "||", ',', '"', [][]string{{"a", "b"}, {"c", "d"}}},
+		{"empty lines", "a,b\n\nc,d\n", "\n", ',', '"', [][]string{{"a", "b"}, {"c", "d"}}},
+		{"single quote", "'a,b',c\n", "\n", ',', '\'', [][]string{{"a,b", "c"}}},
+		{"double quote", "'it''s',c\n", "\n", ',', '\'', [][]string{{"it's", "c"}}},
+		{"backslash quote", `'it\'s',c`, "\n", ',', '\'', [][]string{{"it's", "c"}}},
+		{"multiline", "'a\nb',c\n", "\n", ',', '\'', [][]string{{"a\nb", "c"}}},
+		{"escaped sep", `a\,b,c`, "\n", ',', '"', [][]string{{"a,b", "c"}}},
+		{"backslash", `a\b,c`, "\n", ',', '"', [][]string{{`a\b`, "c"}}},
+		{"tab", "a\tb\rc\td", "\r", '\t', '"', [][]string{{"a", "b"}, {"c", "d"}}},
+	}
+	for _, v := range tests {
+		r := newDialectReader(strings.NewReader(v.in), v.sep, v.quote, v.lineSep)
+		r.fieldsPerRecord = -1
+		var res [][]string
+		for {
+			row, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(err, v.msg)
+			res = append(res, row)
+		}
+		assert.Equal(v.out, res, v.msg)
+	}
+}
+
+func TestDialectReaderFields(t *testing.T) {
+	assert := assert.New(t)
+	r := newDialectReader(strings.NewReader("a,b\nc\n"), ',', '\'', "\n")
+	_, err := r.Read()
+	assert.Nil(err)
+	_, err = r.Read()
+	assert.True(errors.Is(err, errFieldCount))
+
+	r = newDialectReader(strings.NewReader("'a,b\n"), ',', '\'', "\n")
+	_, err = r.Read()
+	assert.NotNil(err)
+}
@@ -3,8 +3,10 @@ package csvsio
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
@@ -12,26 +14,46 @@ import (
 	"github.com/dustin/go-humanize"
 	"github.com/gnames/dwca/internal/ent"
 	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/gnames/dwca/internal/io/encio"
 	"github.com/gnames/gnfmt"
 )
 
 type csvsio struct {
 	a ent.CSVAttr
-	f *os.File
+	f io.ReadCloser
 	r *bufio.Scanner
 	w *bufio.Writer
+
+	// offset is the position in the text after the last scanned line.
+	offset int64
+	// start is the position where the last scanned line starts.
+	start int64
+	// fieldsNum is the number of fields expected in rows.
+	fieldsNum int
 }
 
 func New(attr ent.CSVAttr) (ent.CSVReader, error) {
 	res := &csvsio{a: attr}
 
-	f, err := os.Open(res.a.Path)
+	f, err := res.a.Open()
 	if err != nil {
 		return nil, err
 	}
 	res.f = f
 
-	res.r = bufio.NewScanner(f)
+	in, err := encio.NewReaderAt(f, res.a.Encoding, res.a.Offset)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	res.offset = res.a.Offset
+
+	res.r = bufio.NewScanner(in)
+	split := bufio.ScanLines
+	if !res.a.IsLF() {
+		split = scanLines(res.a.LineSep)
+	}
+	res.r.Split(res.trackOffset(split))
 
 	return res, nil
 }
@@ -49,19 +71,84 @@ func NewWriter(attr ent.CSVAttr) (ent.CSVWriter, error) {
 }
 
 func (c *csvsio) skipHeader() (int, int) {
+	if c.a.Offset > 0 {
+		return c.a.FieldsNum, 0
+	}
+
 	var fieldsNum, lineNum int
-	// ignore headers gif they are given
-	if c.a.IgnoreHeader == "1" {
+	// ignore headers if they are given, the number of fields is taken
+	// from the last header line.
+	for range c.a.HeaderLines() {
+		if !c.r.Scan() {
+			break
+		}
 		lineNum++
-		c.r.Scan()
-		line := c.r.Text()
-		sep := string(c.a.ColSep)
-		row := strings.Split(line, sep)
+		row := c.split(c.r.Text())
 		fieldsNum = len(row)
 	}
 	return fieldsNum, lineNum
 }
 
+// split breaks a line into fields. A field separator preceded by a
+// backslash is a part of a field.
+func (c *csvsio) split(line string) []string {
+	sep := string(c.a.ColSep)
+	esc := `\` + sep
+	if !strings.Contains(line, esc) {
+		return strings.Split(line, sep)
+	}
+
+	var res []string
+	var field strings.Builder
+	for {
+		i := strings.Index(line, sep)
+		if i == -1 {
+			field.WriteString(line)
+			break
+		}
+		if i > 0 && line[i-1] == '\\' {
+			field.WriteString(line[:i-1])
+			field.WriteString(sep)
+			line = line[i+len(sep):]
+			continue
+		}
+		field.WriteString(line[:i])
+		res = append(res, field.String())
+		field.Reset()
+		line = line[i+len(sep):]
+	}
+	return append(res, field.String())
+}
+
+// scanLines creates a split function for lines terminated by sep.
+func scanLines(sep string) bufio.SplitFunc {
+	bs := []byte(sep)
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, bs); i >= 0 {
+			return i + len(bs), data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// trackOffset wraps a split function to keep positions of lines.
+func (c *csvsio) trackOffset(split bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		if token != nil {
+			c.start = c.offset
+		}
+		c.offset += int64(advance)
+		return advance, token, err
+	}
+}
+
 func (c *csvsio) badRow(
 	lineNum, fieldsNum, rowFieldsNum int,
 ) (bool, error) {
@@ -102,19 +189,12 @@ func (c *csvsio) ReadSlice(offset, limit int) ([][]string, error) {
 	var count int
 	for c.r.Scan() {
 		lineNum++
-		count++
 
 		if limit > 0 && len(res) == limit {
 			break
 		}
 
-		if offset > 0 && count <= offset {
-			continue
-		}
-
-		line := c.r.Text()
-		sep := string(c.a.ColSep)
-		row := strings.Split(line, sep)
+		row := c.split(c.r.Text())
 		rowFieldsNum := len(row)
 		if fieldsNum == 0 {
 			fieldsNum = rowFieldsNum
@@ -131,7 +211,13 @@ func (c *csvsio) ReadSlice(offset, limit int) ([][]string, error) {
 			row = gnfmt.NormRowSize(row, fieldsNum)
 		}
 
-		res = append(res, row)
+		// skipped rows are not counted, the same way as in read.
+		count++
+		if offset > 0 && count <= offset {
+			continue
+		}
+
+		res = append(res, c.a.SetDefaults(row))
 	}
 
 	if err := c.r.Err(); err != nil {
@@ -144,6 +230,28 @@ func (c *csvsio) ReadSlice(offset, limit int) ([][]string, error) {
 func (c *csvsio) Read(
 	ctx context.Context,
 	ch chan<- []string,
+) (int, error) {
+	return c.read(ctx, func(_ int64, row []string) {
+		ch <- row
+	})
+}
+
+func (c *csvsio) ReadOffsets(
+	ctx context.Context,
+	ch chan<- ent.OffsetRow,
+) (int, error) {
+	return c.read(ctx, func(offset int64, row []string) {
+		ch <- ent.OffsetRow{Offset: offset, Values: row}
+	})
+}
+
+func (c *csvsio) FieldsNum() int {
+	return c.fieldsNum
+}
+
+func (c *csvsio) read(
+	ctx context.Context,
+	send func(int64, []string),
 ) (int, error) {
 	fieldsNum, lineNum := c.skipHeader()
 
@@ -152,17 +260,16 @@ func (c *csvsio) Read(
 		lineNum++
 
 		if count%100_000 == 0 {
-			fmt.Printf("\r%s", strings.Repeat(" ", 50))
-			fmt.Printf("\rProcessed %s lines", humanize.Comma(count))
+			fmt.Fprintf(os.Stderr, "\r%s", strings.Repeat(" ", 50))
+			fmt.Fprintf(os.Stderr, "\rProcessed %s lines", humanize.Comma(count))
 		}
 
-		line := c.r.Text()
-		sep := string(c.a.ColSep)
-		row := strings.Split(line, sep)
+		row := c.split(c.r.Text())
 		rowFieldsNum := len(row)
 		if fieldsNum == 0 {
 			fieldsNum = rowFieldsNum
 		}
+		c.fieldsNum = fieldsNum
 
 		if fieldsNum != rowFieldsNum {
 			skip, err := c.badRow(lineNum, fieldsNum, rowFieldsNum)
@@ -180,15 +287,19 @@ func (c *csvsio) Read(
 			return 0, &dcfile.ErrContext{Err: ctx.Err()}
 		default:
 			count++
-			ch <- row
+			send(c.start, c.a.SetDefaults(row))
 		}
 	}
 
-	fmt.Printf("\r%s\r", strings.Repeat(" ", 50))
+	fmt.Fprintf(os.Stderr, "\r%s\r", strings.Repeat(" ", 50))
 	return int(count), nil
 }
 
 func (c *csvsio) Close() error {
+	// writers do not keep an open file.
+	if c.f == nil {
+		return nil
+	}
 	return c.f.Close()
 }
 
@@ -0,0 +1,432 @@
+package dcfileio
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/cache"
+	"github.com/gnames/gnsys"
+)
+
+const (
+	// extractCacheDir keeps extracted files of an archive in its cache
+	// entry.
+	extractCacheDir = "extract"
+	// outputCachePrefix starts names of directories with normalized output
+	// in a cache entry.
+	outputCachePrefix = "output-"
+	// tmpCachePrefix starts names of directories that are not complete yet.
+	tmpCachePrefix = ".tmp-"
+	// useCachePrefix starts names of files that mark a cache entry as used
+	// by an archive. It is followed by the process ID of the archive.
+	useCachePrefix = ".use-"
+)
+
+// cacheDir returns the directory of the archive in the cache. It returns
+// an empty string if the cache is not used.
+func (d *dcfileio) cacheDir() string {
+	if !d.cfg.UseCache || d.filePath == "" {
+		return ""
+	}
+	switch d.fileType {
+	case dcfile.ZIP, dcfile.TAR, dcfile.TARGZ, dcfile.TARBZ2, dcfile.TARXZ:
+	default:
+		return ""
+	}
+
+	if d.checksum == "" {
+		sum, err := hash(d.filePath)
+		if err != nil {
+			slog.Warn(
+				"Cannot calculate checksum of the archive, cache is not used",
+				"path", d.filePath, "error", err,
+			)
+			return ""
+		}
+		d.checksum = sum
+	}
+	return filepath.Join(d.cfg.CachePath, d.checksum)
+}
+
+// openCache prepares reading of extracted files from the cache. It
+// returns false if the archive is not in the cache.
+func (d *dcfileio) openCache() bool {
+	dir := d.cacheDir()
+	if dir == "" {
+		return false
+	}
+	// the entry is marked as used before the check. Pruning checks marks
+	// again after moving an entry away, so a marked entry is not removed.
+	use, err := useCache(dir)
+	if err != nil {
+		return false
+	}
+	extDir := filepath.Join(dir, extractCacheDir)
+	if exists, _, _ := gnsys.DirExists(extDir); !exists {
+		_ = use.Close()
+		return false
+	}
+
+	fsys := os.DirFS(extDir)
+	fsDir, err := fsArchiveDir(fsys)
+	if err != nil {
+		_ = use.Close()
+		slog.Warn("Cannot use cached archive", "path", extDir, "error", err)
+		return false
+	}
+	touch(dir)
+	d.fsys = fsys
+	d.fsDir = fsDir
+	// the entry is released when fsys is closed.
+	d.closer = use
+	return true
+}
+
+// cacheUse marks a cache entry as used while it exists.
+type cacheUse struct {
+	path string
+}
+
+// useCache marks a cache entry as used by the current process, so it is
+// not removed by pruning. Entries are read in place, and several archives
+// of one or more processes can read the same entry.
+func useCache(dir string) (*cacheUse, error) {
+	pattern := useCachePrefix + strconv.Itoa(os.Getpid()) + "-*"
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	err = f.Close()
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	return &cacheUse{path: f.Name()}, nil
+}
+
+// Close removes the mark of use from the cache entry.
+func (c *cacheUse) Close() error {
+	err := os.Remove(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// isCacheUsed checks if a cache entry is used by a running process.
+func isCacheUsed(dir string) bool {
+	es, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range es {
+		name, ok := strings.CutPrefix(e.Name(), useCachePrefix)
+		if !ok {
+			continue
+		}
+		pidStr, _, _ := strings.Cut(name, "-")
+		pid, err := strconv.Atoi(pidStr)
+		if err == nil && processRuns(pid) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveCache moves extracted files to the cache, and prepares reading them
+// from there.
+func (d *dcfileio) saveCache() error {
+	dir := d.cacheDir()
+	if dir == "" {
+		return nil
+	}
+	if _, err := d.ArchiveDir(d.cfg.ExtractPath); err != nil {
+		return nil
+	}
+
+	err := gnsys.MakeDir(dir)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(d.cfg.ExtractPath, filepath.Join(dir, extractCacheDir))
+	if err != nil {
+		// another process might have cached the same archive already.
+		slog.Debug("Cannot move extracted files to cache", "error", err)
+		return nil
+	}
+
+	err = gnsys.MakeDir(d.cfg.ExtractPath)
+	if err != nil {
+		return err
+	}
+	if !d.openCache() {
+		return &dcfile.ErrMetaFileNotFound{}
+	}
+	slog.Info("Extracted files are saved to cache", "path", dir)
+	d.pruneCache()
+	return nil
+}
+
+// CachedOutput copies normalized output from the cache to OutputPath.
+func (d *dcfileio) CachedOutput(key string) (bool, error) {
+	dir := d.cacheDir()
+	if dir == "" {
+		return false, nil
+	}
+	src := filepath.Join(dir, outputCachePrefix+key)
+	if exists, _, _ := gnsys.DirExists(src); !exists {
+		return false, nil
+	}
+
+	err := copyDir(src, d.cfg.OutputPath)
+	if err != nil {
+		return false, err
+	}
+	touch(dir)
+	slog.Info("Using cached normalized output", "path", src)
+	return true, nil
+}
+
+// CacheOutput copies normalized output from OutputPath to the cache.
+func (d *dcfileio) CacheOutput(key string) error {
+	dir := d.cacheDir()
+	if dir == "" {
+		return nil
+	}
+	dst := filepath.Join(dir, outputCachePrefix+key)
+	if exists, _, _ := gnsys.DirExists(dst); exists {
+		return nil
+	}
+
+	err := gnsys.MakeDir(dir)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(dir, tmpCachePrefix)
+	if err != nil {
+		return err
+	}
+	err = copyDir(d.cfg.OutputPath, tmp)
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		return err
+	}
+	touch(dir)
+	d.pruneCache()
+	return nil
+}
+
+// pruneCache applies cache limits, keeping the entry of the current
+// archive.
+func (d *dcfileio) pruneCache() {
+	if d.cfg.CacheMaxSize == 0 && d.cfg.CacheMaxAge == 0 {
+		return
+	}
+	_, err := pruneCache(d.cfg, d.checksum)
+	if err != nil {
+		slog.Warn("Cannot prune cache", "error", err)
+	}
+}
+
+// ListCache returns information about archives in the cache, starting
+// from the most recently used.
+func ListCache(cfg config.Config) ([]cache.Entry, error) {
+	es, err := os.ReadDir(cfg.CachePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var res []cache.Entry
+	for _, e := range es {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		entry, err := cacheEntry(filepath.Join(cfg.CachePath, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, entry)
+	}
+	slices.SortFunc(res, func(a, b cache.Entry) int {
+		return b.LastUsed.Compare(a.LastUsed)
+	})
+	return res, nil
+}
+
+// PruneCache removes archives from the cache that are older than
+// cfg.CacheMaxAge, and least recently used archives if the size of the
+// cache is bigger than cfg.CacheMaxSize. It returns removed entries.
+func PruneCache(cfg config.Config) ([]cache.Entry, error) {
+	return pruneCache(cfg, "")
+}
+
+func pruneCache(cfg config.Config, keep string) ([]cache.Entry, error) {
+	es, err := ListCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for _, v := range es {
+		size += v.Size
+	}
+
+	var res []cache.Entry
+	now := time.Now()
+	// entries are sorted from the most recently used, so the oldest ones
+	// are removed first.
+	for i := len(es) - 1; i >= 0; i-- {
+		e := es[i]
+		if e.Checksum == keep {
+			continue
+		}
+		tooOld := cfg.CacheMaxAge > 0 && now.Sub(e.LastUsed) > cfg.CacheMaxAge
+		tooBig := cfg.CacheMaxSize > 0 && size > cfg.CacheMaxSize
+		if !tooOld && !tooBig {
+			continue
+		}
+		// archives read entries in place.
+		if isCacheUsed(e.Path) {
+			slog.Info("Archive in cache is in use, skipping", "path", e.Path)
+			continue
+		}
+		removed, err := removeUnused(cfg.CachePath, e.Path)
+		if err != nil {
+			return res, err
+		}
+		if !removed {
+			slog.Info("Archive in cache is in use, skipping", "path", e.Path)
+			continue
+		}
+		slog.Info("Removed archive from cache", "path", e.Path)
+		size -= e.Size
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// removeUnused removes a cache entry if it is not used. An archive can
+// mark the entry as used after the check of marks, so the entry is moved
+// to a temporary name first, and its marks are checked again. The entry
+// is moved back if it got a mark. It returns false if the entry is kept.
+func removeUnused(cachePath, dir string) (bool, error) {
+	tmp := filepath.Join(
+		cachePath,
+		tmpCachePrefix+filepath.Base(dir)+"-"+strconv.Itoa(os.Getpid()),
+	)
+	err := os.Rename(dir, tmp)
+	if err != nil {
+		// another process might have removed the entry already.
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if isCacheUsed(tmp) {
+		err = os.Rename(tmp, dir)
+		if err != nil {
+			slog.Warn("Cannot restore archive in cache",
+				"path", dir, "error", err)
+		}
+		return false, nil
+	}
+	return true, os.RemoveAll(tmp)
+}
+
+func cacheEntry(dir string) (cache.Entry, error) {
+	res := cache.Entry{Checksum: filepath.Base(dir), Path: dir}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return res, err
+	}
+	res.LastUsed = info.ModTime()
+
+	es, err := os.ReadDir(dir)
+	if err != nil {
+		return res, err
+	}
+	for _, e := range es {
+		if e.IsDir() && strings.HasPrefix(e.Name(), outputCachePrefix) {
+			res.OutputsNum++
+		}
+	}
+
+	err = filepath.WalkDir(dir, func(_ string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			return nil
+		}
+		info, err := e.Info()
+		// marks of use are removed at any time.
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res.Size += info.Size()
+		return nil
+	})
+	return res, err
+}
+
+// touch sets the time of the last use of a cache entry. Adding files to
+// the entry changes this time as well.
+func touch(dir string) {
+	now := time.Now()
+	err := os.Chtimes(dir, now, now)
+	if err != nil {
+		slog.Debug("Cannot update time of cache entry", "path", dir, "error", err)
+	}
+}
+
+// copyDir copies content of the src directory to the dst directory.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if e.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		r, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		w, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		if cErr := w.Close(); err == nil {
+			err = cErr
+		}
+		return err
+	})
+}
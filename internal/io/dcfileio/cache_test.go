@@ -0,0 +1,50 @@
+package dcfileio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveUnused(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	tests := []struct {
+		msg     string
+		used    bool
+		removed bool
+	}{
+		{"unused", false, true},
+		// the mark appears after the entry was checked by pruning.
+		{"used", true, false},
+	}
+	for _, v := range tests {
+		dir := filepath.Join(root, v.msg)
+		err := os.MkdirAll(filepath.Join(dir, extractCacheDir), 0755)
+		assert.Nil(err, v.msg)
+		if v.used {
+			use, err := useCache(dir)
+			assert.Nil(err, v.msg)
+			defer use.Close()
+		}
+
+		removed, err := removeUnused(root, dir)
+		assert.Nil(err, v.msg)
+		assert.Equal(v.removed, removed, v.msg)
+		_, err = os.Stat(filepath.Join(dir, extractCacheDir))
+		assert.Equal(v.removed, os.IsNotExist(err), v.msg)
+	}
+
+	// temporary names are not left behind.
+	es, err := os.ReadDir(root)
+	assert.Nil(err)
+	assert.Equal(1, len(es))
+	assert.Equal("used", es[0].Name())
+
+	// the entry might be removed by another process.
+	removed, err := removeUnused(root, filepath.Join(root, "unused"))
+	assert.Nil(err)
+	assert.False(removed)
+}
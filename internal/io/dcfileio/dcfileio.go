@@ -7,10 +7,13 @@ import (
 	"context"
 	"errors"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/dustin/go-humanize"
 	"github.com/gnames/dwca/internal/ent"
@@ -30,24 +33,75 @@ type dcfileio struct {
 	filePath string
 	// arcPath is the path where all DwCA data files are located.
 	arcPath string
+
+	// fsys is the file system of the archive if it is read without
+	// extraction, otherwise it is nil.
+	fsys fs.FS
+	// fsDir is the directory with meta.xml inside of fsys.
+	fsDir string
+	// closer releases resources of fsys.
+	closer io.Closer
+	// src is the file system with unpacked archive for FS file type.
+	src fs.FS
+	// checksum is SHA-256 of the archive file, it is calculated only if
+	// cache is used.
+	checksum string
+
+	// idx is the index of data files, it is created on demand.
+	idx *index
+	// idxMu protects idx.
+	idxMu sync.Mutex
 }
 
 // New creates a new DCFile object.
 func New(cfg config.Config, path string) (dcfile.DCFile, error) {
 	exists, _ := gnsys.FileExists(path)
+	if !exists {
+		// unpacked archive in a directory
+		exists, _, _ = gnsys.DirExists(path)
+	}
 	if !exists && path != "" && !strings.HasPrefix(path, "http") {
 		return nil, &dcfile.ErrFileNotFound{Path: path}
 	}
 	res := &dcfileio{
 		cfg:      cfg,
 		filePath: path,
-		fileType: dcfile.NewFileType(path),
+		fileType: fileType(path),
+	}
+	return res, nil
+}
+
+// NewFS creates a new DCFile object from a file system that contains
+// unpacked DwCA files.
+func NewFS(cfg config.Config, fsys fs.FS) (dcfile.DCFile, error) {
+	if fsys == nil {
+		return nil, &dcfile.ErrFileNotFound{Path: "fs.FS"}
+	}
+	res := &dcfileio{
+		cfg:      cfg,
+		fileType: dcfile.FS,
+		src:      fsys,
 	}
 	return res, nil
 }
 
+// NewReaderAt creates a new DCFile object from ZIP archive data, for
+// example from a byte slice wrapped into bytes.Reader.
+func NewReaderAt(
+	cfg config.Config,
+	r io.ReaderAt,
+	size int64,
+) (dcfile.DCFile, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, &dcfile.ErrExtract{Path: "io.ReaderAt", Err: err}
+	}
+	return NewFS(cfg, zr)
+}
+
 // ResetTempDirs creates empty filesystem structure for the DwCA archive.
 func (d *dcfileio) ResetTempDirs() error {
+	d.resetIndex()
 	err := d.resetDirs()
 	if err != nil {
 		return err
@@ -57,24 +111,53 @@ func (d *dcfileio) ResetTempDirs() error {
 
 func (d *dcfileio) SetFilePath(path string) {
 	d.filePath = path
-	d.fileType = dcfile.NewFileType(path)
+	d.fileType = fileType(path)
+	d.checksum = ""
+	d.resetIndex()
 }
 
 func (d *dcfileio) Extract() error {
+	d.resetIndex()
+	d.closeFS()
+	// unpacked archives are read in place.
+	if d.fileType == dcfile.DIR || d.fileType == dcfile.FS {
+		return d.openFS()
+	}
+
+	if d.openCache() {
+		slog.Info("Using cached archive", "path", d.filePath)
+		return nil
+	}
+	if d.cfg.StreamArchive {
+		err := d.openFS()
+		if err == nil {
+			return nil
+		}
+		slog.Warn(
+			"Cannot read archive without extraction, extracting it",
+			"path", d.filePath, "error", err,
+		)
+	}
+
+	var err error
 	switch d.fileType {
 	case dcfile.TAR:
-		return d.extractTar()
+		err = d.extractTar()
 	case dcfile.TARGZ:
-		return d.extractTarGz()
+		err = d.extractTarGz()
 	case dcfile.TARBZ2:
-		return d.extractTarBz2()
+		err = d.extractTarBz2()
 	case dcfile.TARXZ:
-		return d.extractTarXz()
+		err = d.extractTarXz()
 	case dcfile.ZIP:
-		return d.extractZip()
+		err = d.extractZip()
 	default:
 		return &dcfile.ErrUnknownArchiveType{FileType: d.fileType}
 	}
+	if err != nil {
+		return err
+	}
+	return d.saveCache()
 }
 
 // ArchiveDir determines the directory where the files of DarwinCore archive
@@ -121,26 +204,17 @@ func (d *dcfileio) CoreData(
 		return nil, &dcfile.ErrCoreRead{Err: errors.New("*meta.Meta is nil")}
 	}
 
-	path, err := d.basePath(root, meta.Core.Files.Location)
+	attrs, err := d.csvAttrs(root, meta.Core.Attr)
 	if err != nil {
 		return nil, err
 	}
 
-	attr := ent.CSVAttr{
-		Path:             path,
-		ColSep:           colSep(meta.Core.FieldsTerminatedBy),
-		Quote:            meta.Core.FieldsEnclosedBy,
-		IgnoreHeader:     meta.Core.IgnoreHeaderLines,
-		BadRowProcessing: d.cfg.WrongFieldsNum,
+	var res [][]string
+	if idx := d.loadedIndex(root); idx != nil {
+		res, err = idx.core.slice(offset, limit)
+	} else {
+		res, err = readSlice(attrs, offset, limit)
 	}
-
-	r, err := factory.CSVReader(attr)
-	if err != nil {
-		return nil, err
-	}
-	defer r.Close()
-
-	res, err := r.ReadSlice(offset, limit)
 	if err != nil {
 		return nil, &dcfile.ErrCoreRead{Err: err}
 	}
@@ -154,33 +228,20 @@ func (d *dcfileio) CoreStream(
 	meta *meta.Meta,
 	coreChan chan<- []string,
 ) (int, error) {
-	path, err := d.basePath(root, meta.Core.Files.Location)
-	if err != nil {
-		return 0, err
-	}
-	attr := ent.CSVAttr{
-		Path:             path,
-		ColSep:           colSep(meta.Core.FieldsTerminatedBy),
-		Quote:            meta.Core.FieldsEnclosedBy,
-		IgnoreHeader:     meta.Core.IgnoreHeaderLines,
-		BadRowProcessing: d.cfg.WrongFieldsNum,
-	}
+	defer close(coreChan)
 
-	r, err := factory.CSVReader(attr)
+	attrs, err := d.csvAttrs(root, meta.Core.Attr)
 	if err != nil {
 		return 0, err
 	}
-	defer r.Close()
-
-	count, err := r.Read(ctx, coreChan)
-	close(coreChan)
 
+	count, err := readStream(ctx, attrs, coreChan)
 	if err != nil {
-		return int(count), &dcfile.ErrExtensionRead{Err: err}
+		return count, &dcfile.ErrExtensionRead{Err: err}
 	}
 	slog.Info("Processed core", "lines", humanize.Comma(int64(count)))
 
-	return int(count), nil
+	return count, nil
 }
 
 func (d *dcfileio) ExtensionData(
@@ -193,27 +254,17 @@ func (d *dcfileio) ExtensionData(
 		return nil, &dcfile.ErrExtensionRead{Err: errors.New("index out of range")}
 	}
 
-	ext := meta.Extensions[index]
-	path, err := d.basePath(root, ext.Files.Location)
+	attrs, err := d.csvAttrs(root, meta.Extensions[index].Attr)
 	if err != nil {
 		return nil, err
 	}
 
-	attr := ent.CSVAttr{
-		Path:             path,
-		ColSep:           colSep(ext.FieldsTerminatedBy),
-		Quote:            ext.FieldsEnclosedBy,
-		IgnoreHeader:     ext.IgnoreHeaderLines,
-		BadRowProcessing: d.cfg.WrongFieldsNum,
+	var res [][]string
+	if idx := d.loadedIndex(root); idx != nil && index < len(idx.exts) {
+		res, err = idx.exts[index].slice(offset, limit)
+	} else {
+		res, err = readSlice(attrs, offset, limit)
 	}
-
-	r, err := factory.CSVReader(attr)
-	if err != nil {
-		return nil, err
-	}
-	defer r.Close()
-
-	res, err := r.ReadSlice(offset, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -228,6 +279,8 @@ func (d *dcfileio) ExtensionStream(
 	meta *meta.Meta,
 	extChan chan<- []string,
 ) (int, error) {
+	defer close(extChan)
+
 	if meta == nil {
 		return 0, &dcfile.ErrExtensionRead{Err: errors.New("*meta.Meta is nil")}
 	}
@@ -238,36 +291,20 @@ func (d *dcfileio) ExtensionStream(
 	extType := ext.RowType
 	extType = filepath.Base(extType)
 
-	path, err := d.basePath(root, ext.Files.Location)
-	if err != nil {
-		return 0, err
-	}
-
-	attr := ent.CSVAttr{
-		Path:             path,
-		ColSep:           colSep(ext.FieldsTerminatedBy),
-		Quote:            ext.FieldsEnclosedBy,
-		IgnoreHeader:     ext.IgnoreHeaderLines,
-		BadRowProcessing: d.cfg.WrongFieldsNum,
-	}
-
-	r, err := factory.CSVReader(attr)
+	attrs, err := d.csvAttrs(root, ext.Attr)
 	if err != nil {
 		return 0, err
 	}
-	defer r.Close()
-
-	count, err := r.Read(ctx, extChan)
-	close(extChan)
 
+	count, err := readStream(ctx, attrs, extChan)
 	if err != nil {
-		return int(count), &dcfile.ErrExtensionRead{Err: err}
+		return count, &dcfile.ErrExtensionRead{Err: err}
 	}
 
 	slog.Info(
 		"Processed extension", "lines", humanize.Comma(int64(count)), "ext", extType,
 	)
-	return int(count), nil
+	return count, nil
 }
 
 func (d *dcfileio) ExportCSVStream(
@@ -297,6 +334,14 @@ func (d *dcfileio) ExportCSVStream(
 	return nil
 }
 
+func (d *dcfileio) RemoveOutput(file string) error {
+	err := os.Remove(filepath.Join(d.cfg.OutputPath, file))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // 	f, err := os.Create(path)
 // 	if err != nil {
 // 		return &dcfile.ErrSaveCSV{Err: err}
@@ -444,7 +489,15 @@ func (d *dcfileio) TarGz(inputDir, fileTar string) error {
 }
 
 func (d *dcfileio) Close() error {
-	err := os.RemoveAll(d.cfg.ExtractPath)
+	d.resetIndex()
+	err := d.closeFS()
+	if err != nil {
+		return err
+	}
+	if isWorkDir(d.cfg) {
+		return os.RemoveAll(d.cfg.WorkPath)
+	}
+	err = os.RemoveAll(d.cfg.ExtractPath)
 	if err != nil {
 		return err
 	}
@@ -455,22 +508,36 @@ func (d *dcfileio) Close() error {
 	return os.RemoveAll(d.cfg.OutputPath)
 }
 
-func colSep(s string) rune {
-	if s == "\\t" {
-		return '\t'
+// fileType detects the type of the DwCA file by its path.
+func fileType(path string) dcfile.FileType {
+	if path != "" {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return dcfile.DIR
+		}
 	}
+	return dcfile.NewFileType(path)
+}
 
-	if len(s) > 0 {
-		return rune(s[0])
-	}
-	return ','
+// metaDefaults returns default values of fields by their indices.
+func metaDefaults(fields []meta.Field) map[int]string {
+	var res map[int]string
+	for _, v := range fields {
+		if v.Default == "" || v.Idx < 0 {
+			continue
+		}
+		if res == nil {
+			res = make(map[int]string)
+		}
+		res[v.Idx] = v.Default
+	}
+	return res
 }
 
-func (d *dcfileio) basePath(root, filePath string) (string, error) {
-	path, err := d.ArchiveDir(root)
-	if err != nil {
-		return "", err
+func colSep(s string) rune {
+	s = ent.Unescape(s)
+	if s == "" {
+		return ','
 	}
-	path = filepath.Join(path, filePath)
-	return path, nil
+	res, _ := utf8.DecodeRuneInString(s)
+	return res
 }
@@ -1,6 +1,7 @@
 package dcfileio_test
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -116,3 +117,57 @@ func TestMetaDir(t *testing.T) {
 		assert.Nil(err)
 	}
 }
+
+func TestWorkDir(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	cfg := config.New(config.OptRootPath(root))
+
+	// working directory of a process that does not run anymore.
+	stale := filepath.Join(root, "arc-stale")
+	err := os.MkdirAll(filepath.Join(stale, "extract"), 0755)
+	assert.Nil(err)
+	err = os.WriteFile(filepath.Join(stale, ".lock"), []byte("99999999"), 0644)
+	assert.Nil(err)
+
+	cfg1, err := dcfileio.NewWorkDir(cfg)
+	assert.Nil(err)
+	cfg2, err := dcfileio.NewWorkDir(cfg)
+	assert.Nil(err)
+	assert.NoDirExists(stale)
+
+	assert.NotEqual(cfg1.WorkPath, cfg2.WorkPath)
+	for _, v := range []config.Config{cfg1, cfg2} {
+		assert.Equal(root, filepath.Dir(v.WorkPath))
+		assert.Equal(filepath.Join(v.WorkPath, "extract"), v.ExtractPath)
+		assert.Equal(filepath.Join(v.WorkPath, "output"), v.OutputPath)
+		assert.FileExists(filepath.Join(v.WorkPath, ".lock"))
+	}
+
+	path := filepath.Join("..", "..", "..", "pkg", "testdata", "data.tar")
+	df1, err := dcfileio.New(cfg1, path)
+	assert.Nil(err)
+	df2, err := dcfileio.New(cfg2, path)
+	assert.Nil(err)
+	for _, df := range []dcfile.DCFile{df1, df2} {
+		err = df.ResetTempDirs()
+		assert.Nil(err)
+		err = df.Extract()
+		assert.Nil(err)
+	}
+
+	// resetting one working directory does not affect another one.
+	err = df1.ResetTempDirs()
+	assert.Nil(err)
+	assert.FileExists(filepath.Join(cfg1.WorkPath, ".lock"))
+	assert.FileExists(filepath.Join(cfg2.ExtractPath, "meta.xml"))
+
+	err = df1.Close()
+	assert.Nil(err)
+	assert.NoDirExists(cfg1.WorkPath)
+	assert.FileExists(filepath.Join(cfg2.ExtractPath, "meta.xml"))
+
+	err = df2.Close()
+	assert.Nil(err)
+	assert.NoDirExists(cfg2.WorkPath)
+}
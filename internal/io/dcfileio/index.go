@@ -0,0 +1,595 @@
+package dcfileio
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/gnames/dwca/internal/io/encio"
+	"github.com/gnames/dwca/internal/io/factory"
+	"github.com/gnames/dwca/pkg/ent/meta"
+	"github.com/gnames/gnsys"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// offsetSize is the size of a row position in an offsets file: the
+// number of the data file (uint32) and the offset in its text (int64).
+const offsetSize = 12
+
+// index keeps positions of rows of Core and Extension files, and numbers
+// of rows that correspond to IDs and core IDs. Positions are kept in
+// files, so the index does not need much memory, and the cached index can
+// be reused by other runs.
+type index struct {
+	// root is the directory the index was built for.
+	root string
+	core *dataIndex
+	exts []*dataIndex
+}
+
+// dataIndex is the index of a Core or an Extension.
+type dataIndex struct {
+	// Files contains information about data files of the Core or the
+	// Extension.
+	Files []indexedFile
+
+	// RowsNum is the total number of rows in data files.
+	RowsNum int
+
+	// Keys maps IDs (core IDs for extensions) to numbers of rows.
+	Keys map[string][]int
+
+	attrs   []ent.CSVAttr
+	offsets *os.File
+}
+
+// indexedFile describes a data file at the time it was indexed.
+type indexedFile struct {
+	Path      string
+	Size      int64
+	ModTime   time.Time
+	FieldsNum int
+
+	// Copy is the name of the UTF-8 copy of the data file in the index
+	// directory. It is empty if rows are read from the data file itself.
+	Copy string
+}
+
+// Index builds the index of the Core and Extension rows for the given
+// root, or opens the index that was built before.
+func (d *dcfileio) Index(root string, meta *meta.Meta) error {
+	_, err := d.index(root, meta)
+	return err
+}
+
+// CoreByID returns Core rows with the given ID.
+func (d *dcfileio) CoreByID(
+	root string,
+	meta *meta.Meta,
+	id string,
+) ([]dcfile.Row, error) {
+	idx, err := d.index(root, meta)
+	if err != nil {
+		return nil, err
+	}
+	res, err := idx.core.byKey(id)
+	if err != nil {
+		return nil, &dcfile.ErrCoreRead{Err: err}
+	}
+	return res, nil
+}
+
+// ExtensionByCoreID returns rows of the extension with the given index
+// that refer to the given core ID.
+func (d *dcfileio) ExtensionByCoreID(
+	index int,
+	root string,
+	meta *meta.Meta,
+	coreID string,
+) ([]dcfile.Row, error) {
+	idx, err := d.index(root, meta)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx.exts) <= index {
+		return nil, &dcfile.ErrExtensionRead{Err: errors.New("index out of range")}
+	}
+	res, err := idx.exts[index].byKey(coreID)
+	if err != nil {
+		return nil, &dcfile.ErrExtensionRead{Err: err}
+	}
+	return res, nil
+}
+
+// index returns the index for the root, creating it if necessary.
+func (d *dcfileio) index(root string, meta *meta.Meta) (*index, error) {
+	if meta == nil {
+		return nil, &dcfile.ErrIndex{Err: errors.New("*meta.Meta is nil")}
+	}
+
+	d.idxMu.Lock()
+	defer d.idxMu.Unlock()
+	if d.idx != nil && d.idx.root == root {
+		return d.idx, nil
+	}
+	d.closeIndex()
+
+	res, err := d.openIndex(root, meta)
+	if err != nil {
+		return nil, &dcfile.ErrIndex{Err: err}
+	}
+	d.idx = res
+	return res, nil
+}
+
+// loadedIndex returns the index for the root, if it was already created.
+func (d *dcfileio) loadedIndex(root string) *index {
+	d.idxMu.Lock()
+	defer d.idxMu.Unlock()
+	if d.idx == nil || d.idx.root != root {
+		return nil
+	}
+	return d.idx
+}
+
+// resetIndex closes the index, because data files are going to change.
+func (d *dcfileio) resetIndex() {
+	d.idxMu.Lock()
+	defer d.idxMu.Unlock()
+	d.closeIndex()
+}
+
+// closeIndex closes files of the index. It is called with idxMu locked.
+func (d *dcfileio) closeIndex() {
+	if d.idx == nil {
+		return
+	}
+	d.idx.close()
+	d.idx = nil
+}
+
+// indexDir returns the directory for the index of the root. Indices of
+// cached archives are kept in the cache.
+func (d *dcfileio) indexDir(root string) string {
+	name := fmt.Sprintf("index-%d", d.cfg.WrongFieldsNum)
+	if dir := d.cacheDir(); dir != "" && root == d.cfg.ExtractPath {
+		return filepath.Join(dir, name)
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(d.cfg.WorkPath, fmt.Sprintf("%s-%x", name, sum[:4]))
+}
+
+// openIndex opens the index for the root. The index is built if it does
+// not exist, or if data files changed since it was built.
+func (d *dcfileio) openIndex(root string, meta *meta.Meta) (*index, error) {
+	attrs := make([][]ent.CSVAttr, len(meta.Extensions)+1)
+	keyIdx := make([]int, len(meta.Extensions)+1)
+	var err error
+	attrs[0], err = d.csvAttrs(root, meta.Core.Attr)
+	if err != nil {
+		return nil, err
+	}
+	keyIdx[0] = meta.Core.ID.Idx
+	for i, v := range meta.Extensions {
+		attrs[i+1], err = d.csvAttrs(root, v.Attr)
+		if err != nil {
+			return nil, err
+		}
+		keyIdx[i+1] = v.CoreID.Idx
+	}
+
+	dir := d.indexDir(root)
+	res, err := loadIndex(dir, attrs)
+	if err == nil {
+		slog.Info("Using index of data files", "path", dir)
+		res.root = root
+		return res, nil
+	}
+	slog.Debug("Index cannot be used", "path", dir, "error", err)
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = buildIndex(dir, attrs, keyIdx)
+	if err != nil {
+		return nil, err
+	}
+	res, err = loadIndex(dir, attrs)
+	if err != nil {
+		return nil, err
+	}
+	res.root = root
+	return res, nil
+}
+
+// indexName returns the name of the index files for the Core (0) or an
+// Extension (index in meta.xml + 1).
+func indexName(i int) string {
+	if i == 0 {
+		return "core"
+	}
+	return fmt.Sprintf("ext-%d", i-1)
+}
+
+// buildIndex reads all data files and saves positions of their rows. The
+// index is created in a temporary directory that is renamed to dir, so an
+// incomplete index is never used.
+func buildIndex(dir string, attrs [][]ent.CSVAttr, keyIdx []int) error {
+	slog.Info("Building index of data files")
+	err := os.MkdirAll(filepath.Dir(dir), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), tmpCachePrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	for i, v := range attrs {
+		err = buildDataIndex(tmp, indexName(i), v, keyIdx[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Rename(tmp, dir)
+	if err != nil {
+		// another process might have built the same index already.
+		if exists, _, _ := gnsys.DirExists(dir); exists {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func buildDataIndex(
+	dir, name string,
+	attrs []ent.CSVAttr,
+	keyIdx int,
+) error {
+	f, err := os.Create(filepath.Join(dir, name+".off"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	res := dataIndex{Keys: make(map[string][]int)}
+	buf := make([]byte, offsetSize)
+	for i, attr := range attrs {
+		file, err := statFile(attr)
+		if err != nil {
+			return err
+		}
+
+		isCopy, err := needsCopy(attr)
+		if err != nil {
+			return err
+		}
+		if isCopy {
+			file.Copy = fmt.Sprintf("%s-%d.txt", name, i)
+			attr, err = copyData(attr, filepath.Join(dir, file.Copy))
+			if err != nil {
+				return err
+			}
+		}
+
+		fieldsNum, err := readOffsets(attr, func(row ent.OffsetRow) error {
+			binary.LittleEndian.PutUint32(buf, uint32(i))
+			binary.LittleEndian.PutUint64(buf[4:], uint64(row.Offset))
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			if keyIdx >= 0 && keyIdx < len(row.Values) {
+				key := row.Values[keyIdx]
+				res.Keys[key] = append(res.Keys[key], res.RowsNum)
+			}
+			res.RowsNum++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		file.FieldsNum = fieldsNum
+		res.Files = append(res.Files, file)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	g, err := os.Create(filepath.Join(dir, name+".gob"))
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+	gw := bufio.NewWriter(g)
+	err = gob.NewEncoder(gw).Encode(res)
+	if err != nil {
+		return err
+	}
+	err = gw.Flush()
+	if err != nil {
+		return err
+	}
+	return g.Close()
+}
+
+// needsCopy checks if a data file has to be copied to the index. Rows
+// are read from their offsets, so files that are not in UTF-8, or do
+// not support seeking (for example files of a compressed archive), are
+// copied as UTF-8 text. Otherwise every row would be read by converting
+// and discarding all the text before it.
+func needsCopy(attr ent.CSVAttr) (bool, error) {
+	if encio.Encoding(attr.Encoding) != unicode.UTF8 {
+		return true, nil
+	}
+	f, err := attr.Open()
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	_, ok := f.(io.ReadSeeker)
+	return !ok, nil
+}
+
+// copyData saves the text of a data file in UTF-8 to the given path. It
+// returns attributes for reading the copy.
+func copyData(attr ent.CSVAttr, path string) (ent.CSVAttr, error) {
+	f, err := attr.Open()
+	if err != nil {
+		return attr, err
+	}
+	defer f.Close()
+
+	w, err := os.Create(path)
+	if err != nil {
+		return attr, err
+	}
+	defer w.Close()
+
+	_, err = io.Copy(w, encio.NewReader(f, attr.Encoding))
+	if err != nil {
+		return attr, err
+	}
+	err = w.Close()
+	if err != nil {
+		return attr, err
+	}
+	return copyAttr(attr, path), nil
+}
+
+// copyAttr returns attributes for reading the UTF-8 copy of a data file.
+func copyAttr(attr ent.CSVAttr, path string) ent.CSVAttr {
+	attr.Path = path
+	attr.FS = nil
+	attr.Encoding = ""
+	return attr
+}
+
+// readOffsets reads rows of a data file with their positions. It returns
+// the number of fields in rows of the file.
+func readOffsets(attr ent.CSVAttr, fn func(ent.OffsetRow) error) (int, error) {
+	r, err := factory.CSVReader(attr)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	ch := make(chan ent.OffsetRow)
+	g, ctx := errgroup.WithContext(context.Background())
+	g.Go(func() error {
+		defer close(ch)
+		_, err := r.ReadOffsets(ctx, ch)
+		return err
+	})
+	g.Go(func() error {
+		for row := range ch {
+			if err := fn(row); err != nil {
+				for range ch {
+				}
+				return err
+			}
+		}
+		return nil
+	})
+
+	err = g.Wait()
+	return r.FieldsNum(), err
+}
+
+// loadIndex opens the index saved in dir. It returns an error if the
+// index does not exist, or data files changed after it was built.
+func loadIndex(dir string, attrs [][]ent.CSVAttr) (*index, error) {
+	res := &index{}
+	for i, v := range attrs {
+		di, err := loadDataIndex(dir, indexName(i), v)
+		if err != nil {
+			res.close()
+			return nil, err
+		}
+		if i == 0 {
+			res.core = di
+			continue
+		}
+		res.exts = append(res.exts, di)
+	}
+	return res, nil
+}
+
+func loadDataIndex(
+	dir, name string,
+	attrs []ent.CSVAttr,
+) (*dataIndex, error) {
+	g, err := os.Open(filepath.Join(dir, name+".gob"))
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+
+	var res dataIndex
+	err = gob.NewDecoder(bufio.NewReader(g)).Decode(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Files) != len(attrs) {
+		return nil, errors.New("data files changed")
+	}
+	for i, v := range attrs {
+		file, err := statFile(v)
+		if err != nil {
+			return nil, err
+		}
+		old := res.Files[i]
+		if file.Path != old.Path || file.Size != old.Size ||
+			!file.ModTime.Equal(old.ModTime) {
+			return nil, fmt.Errorf("data file '%s' changed", v.Path)
+		}
+	}
+
+	res.attrs = make([]ent.CSVAttr, len(attrs))
+	for i, v := range attrs {
+		if cp := res.Files[i].Copy; cp != "" {
+			path := filepath.Join(dir, cp)
+			if _, err = os.Stat(path); err != nil {
+				return nil, err
+			}
+			v = copyAttr(v, path)
+		}
+		res.attrs[i] = v
+	}
+	res.offsets, err = os.Open(filepath.Join(dir, name+".off"))
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func statFile(attr ent.CSVAttr) (indexedFile, error) {
+	res := indexedFile{Path: attr.Path}
+	var info fs.FileInfo
+	var err error
+	if attr.FS != nil {
+		info, err = fs.Stat(attr.FS, attr.Path)
+	} else {
+		info, err = os.Stat(attr.Path)
+	}
+	if err != nil {
+		return res, err
+	}
+	res.Size = info.Size()
+	res.ModTime = info.ModTime()
+	return res, nil
+}
+
+func (idx *index) close() {
+	if idx.core != nil {
+		idx.core.close()
+	}
+	for _, v := range idx.exts {
+		v.close()
+	}
+}
+
+func (di *dataIndex) close() {
+	if di.offsets != nil {
+		di.offsets.Close()
+	}
+}
+
+// position returns the number of the data file and the offset of the row
+// with the given number.
+func (di *dataIndex) position(num int) (int, int64, error) {
+	buf := make([]byte, offsetSize)
+	_, err := di.offsets.ReadAt(buf, int64(num)*offsetSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	file := int(binary.LittleEndian.Uint32(buf))
+	offset := int64(binary.LittleEndian.Uint64(buf[4:]))
+	return file, offset, nil
+}
+
+// slice returns rows according to the offset and limit. Reading starts
+// at the position of the first row, so previous rows are not scanned.
+func (di *dataIndex) slice(offset, limit int) ([][]string, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= di.RowsNum {
+		return nil, nil
+	}
+	file, pos, err := di.position(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var res [][]string
+	for i := file; i < len(di.attrs); i++ {
+		attr := di.attrs[i]
+		if i == file {
+			attr.Offset = pos
+			attr.FieldsNum = di.Files[i].FieldsNum
+		}
+		var need int
+		if limit > 0 {
+			need = limit - len(res)
+		}
+		rows, err := readFileSlice(attr, need)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rows...)
+		if limit > 0 && len(res) >= limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// byKey returns rows with the given ID or core ID. Adjacent rows are
+// read together, so a file is not reopened for every row.
+func (di *dataIndex) byKey(key string) ([]dcfile.Row, error) {
+	nums := di.Keys[key]
+	if len(nums) == 0 {
+		return nil, nil
+	}
+	res := make([]dcfile.Row, 0, len(nums))
+	for i := 0; i < len(nums); {
+		j := i + 1
+		for j < len(nums) && nums[j] == nums[j-1]+1 {
+			j++
+		}
+		rows, err := di.slice(nums[i], j-i)
+		if err != nil {
+			return nil, err
+		}
+		for k, row := range rows {
+			res = append(res, dcfile.Row{RowNum: nums[i] + k + 1, Values: row})
+		}
+		i = j
+	}
+	return res, nil
+}
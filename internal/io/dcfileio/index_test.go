@@ -0,0 +1,80 @@
+package dcfileio
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/stretchr/testify/assert"
+)
+
+// noSeekFS hides Seek method of files, like entries of compressed
+// archives.
+type noSeekFS struct {
+	fs.FS
+}
+
+func (n noSeekFS) Open(name string) (fs.File, error) {
+	f, err := n.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ fs.File }{f}, nil
+}
+
+func TestIndexCopy(t *testing.T) {
+	assert := assert.New(t)
+	utf8 := "taxonID\tscientificName\n1\tLinné\n2\tCarl\n2\tCarolus\n3\tPinus\n"
+	latin1 := "taxonID\tscientificName\n1\tLinn\xe9\n2\tCarl\n2\tCarolus\n3\tPinus\n"
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "utf8.txt"), []byte(utf8), 0644)
+	assert.Nil(err)
+	err = os.WriteFile(filepath.Join(dir, "latin1.txt"), []byte(latin1), 0644)
+	assert.Nil(err)
+	mfs := fstest.MapFS{"utf8.txt": &fstest.MapFile{Data: []byte(utf8)}}
+
+	tests := []struct {
+		msg    string
+		attr   ent.CSVAttr
+		isCopy bool
+	}{
+		{"utf8", ent.CSVAttr{Path: filepath.Join(dir, "utf8.txt")}, false},
+		{"latin1", ent.CSVAttr{
+			Path: filepath.Join(dir, "latin1.txt"), Encoding: "ISO-8859-1",
+		}, true},
+		{"seeker fs", ent.CSVAttr{Path: "utf8.txt", FS: mfs}, false},
+		{"no seeker fs", ent.CSVAttr{Path: "utf8.txt", FS: noSeekFS{mfs}}, true},
+	}
+	for _, v := range tests {
+		v.attr.ColSep = '\t'
+		v.attr.IgnoreHeader = "1"
+		idxDir := filepath.Join(dir, "index", v.msg)
+		attrs := [][]ent.CSVAttr{{v.attr}}
+		err = buildIndex(idxDir, attrs, []int{0})
+		assert.Nil(err, v.msg)
+		idx, err := loadIndex(idxDir, attrs)
+		assert.Nil(err, v.msg)
+
+		file := idx.core.Files[0]
+		assert.Equal(v.isCopy, file.Copy != "", v.msg)
+		assert.Equal(v.isCopy, idx.core.attrs[0].Path != v.attr.Path, v.msg)
+
+		rows, err := idx.core.slice(1, 2)
+		assert.Nil(err, v.msg)
+		assert.Equal([][]string{{"2", "Carl"}, {"2", "Carolus"}}, rows, v.msg)
+
+		res, err := idx.core.byKey("1")
+		assert.Nil(err, v.msg)
+		assert.Equal(1, len(res), v.msg)
+		assert.Equal([]string{"1", "Linné"}, res[0].Values, v.msg)
+
+		res, err = idx.core.byKey("2")
+		assert.Nil(err, v.msg)
+		assert.Equal(2, len(res), v.msg)
+		assert.Equal("Carolus", res[1].Values[1], v.msg)
+		idx.close()
+	}
+}
@@ -1,6 +1,8 @@
 package dcfileio
 
 import (
+	"os"
+
 	"github.com/gnames/dwca/internal/ent/dcfile"
 	"github.com/gnames/gnsys"
 )
@@ -29,15 +31,22 @@ func (d *dcfileio) resetDirs() error {
 	return nil
 }
 
+// rootDir prepares the working directory. Only download, extract and
+// output directories are removed from it, so other archives that keep
+// their working directories in the same root are not affected, and the
+// lock file stays in place.
 func (d *dcfileio) rootDir() error {
-	switch gnsys.GetDirState(d.cfg.RootPath) {
-	case gnsys.DirAbsent:
-		return gnsys.MakeDir(d.cfg.RootPath)
-	case gnsys.DirEmpty:
-		return nil
-	case gnsys.DirNotEmpty:
-		return gnsys.CleanDir(d.cfg.RootPath)
-	default:
-		return &dcfile.ErrDir{}
+	err := gnsys.MakeDir(d.cfg.WorkPath)
+	if err != nil {
+		return &dcfile.ErrDir{DirPath: d.cfg.WorkPath}
 	}
+	for _, v := range []string{
+		d.cfg.DownloadPath, d.cfg.ExtractPath, d.cfg.OutputPath,
+	} {
+		err = os.RemoveAll(v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
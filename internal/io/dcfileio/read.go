@@ -0,0 +1,142 @@
+package dcfileio
+
+import (
+	"context"
+	"path"
+
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/gnames/dwca/internal/io/factory"
+	"github.com/gnames/dwca/pkg/ent/meta"
+	"github.com/gnames/gnfmt"
+)
+
+// csvAttrs creates CSV attributes for every file of a Core or an
+// Extension.
+func (d *dcfileio) csvAttrs(root string, attr *meta.Attr) ([]ent.CSVAttr, error) {
+	fsys, dir, err := d.FS(root)
+	if err != nil {
+		return nil, err
+	}
+
+	files := attr.Files.Paths()
+	if len(files) == 0 {
+		// the path points to the archive directory, so reading fails.
+		files = []string{""}
+	}
+
+	res := make([]ent.CSVAttr, len(files))
+	for i, v := range files {
+		res[i] = ent.CSVAttr{
+			Path:             path.Join(dir, v),
+			FS:               fsys,
+			Encoding:         attr.Encoding,
+			ColSep:           colSep(attr.FieldsTerminatedBy),
+			Quote:            ent.Unescape(attr.FieldsEnclosedBy),
+			LineSep:          ent.Unescape(attr.LinesTerminatedBy),
+			IgnoreHeader:     attr.IgnoreHeaderLines,
+			BadRowProcessing: d.cfg.WrongFieldsNum,
+			Defaults:         metaDefaults(attr.Fields),
+		}
+	}
+	return res, nil
+}
+
+// readSlice reads rows from one or more CSV files. Files are read one
+// after another, offset and limit are applied to the concatenated data.
+func readSlice(attrs []ent.CSVAttr, offset, limit int) ([][]string, error) {
+	if len(attrs) == 1 {
+		r, err := factory.CSVReader(attrs[0])
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return r.ReadSlice(offset, limit)
+	}
+
+	var res [][]string
+	for _, v := range attrs {
+		var need int
+		if limit > 0 {
+			need = offset + limit - len(res)
+			if need <= 0 {
+				break
+			}
+		}
+		rows, err := readFileSlice(v, need)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rows...)
+	}
+
+	if offset >= len(res) {
+		return nil, nil
+	}
+	res = res[offset:]
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func readFileSlice(attr ent.CSVAttr, limit int) ([][]string, error) {
+	r, err := factory.CSVReader(attr)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return r.ReadSlice(0, limit)
+}
+
+// readStream sends rows from one or more CSV files to a channel. Files
+// are read one after another. It returns the total number of rows.
+func readStream(
+	ctx context.Context,
+	attrs []ent.CSVAttr,
+	ch chan<- []string,
+) (int, error) {
+	var res int
+	for _, v := range attrs {
+		count, err := readFileStream(ctx, v, ch)
+		res += count
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func readFileStream(
+	ctx context.Context,
+	attr ent.CSVAttr,
+	ch chan<- []string,
+) (int, error) {
+	r, err := factory.CSVReader(attr)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return r.Read(ctx, ch)
+}
+
+func (d *dcfileio) FieldsNum(root string, attr *meta.Attr) (int, error) {
+	attrs, err := d.csvAttrs(root, attr)
+	if err != nil {
+		return 0, err
+	}
+
+	var res int
+	for _, v := range attrs {
+		// rows are normalized to the size of the header.
+		v.Defaults = nil
+		v.BadRowProcessing = gnfmt.ProcessBadRow
+		rows, err := readFileSlice(v, 1)
+		if err != nil {
+			return 0, err
+		}
+		if len(rows) > 0 {
+			res = max(res, len(rows[0]))
+		}
+	}
+	return res, nil
+}
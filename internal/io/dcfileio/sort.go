@@ -0,0 +1,300 @@
+package dcfileio
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/gnames/dwca/pkg/ent/meta"
+	"golang.org/x/sync/errgroup"
+)
+
+// sortChunkSize is the maximum number of rows that are sorted in memory.
+// Larger files are sorted in chunks that are saved to temporary files and
+// merged.
+var sortChunkSize = 100_000
+
+func (d *dcfileio) SortedCoreStream(
+	ctx context.Context,
+	root string,
+	meta *meta.Meta,
+	ch chan<- dcfile.Row,
+) (int, error) {
+	defer close(ch)
+	if meta == nil {
+		return 0, &dcfile.ErrCoreRead{Err: errors.New("*meta.Meta is nil")}
+	}
+
+	attrs, err := d.csvAttrs(root, meta.Core.Attr)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := d.sortedStream(ctx, attrs, meta.Core.ID.Idx, ch)
+	if err != nil {
+		return count, &dcfile.ErrCoreRead{Err: err}
+	}
+	return count, nil
+}
+
+func (d *dcfileio) SortedExtensionStream(
+	ctx context.Context,
+	index int,
+	root string,
+	meta *meta.Meta,
+	ch chan<- dcfile.Row,
+) (int, error) {
+	defer close(ch)
+	if meta == nil {
+		return 0, &dcfile.ErrExtensionRead{Err: errors.New("*meta.Meta is nil")}
+	}
+	if len(meta.Extensions) <= index {
+		return 0, &dcfile.ErrExtensionRead{Err: errors.New("index out of range")}
+	}
+	ext := meta.Extensions[index]
+
+	attrs, err := d.csvAttrs(root, ext.Attr)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := d.sortedStream(ctx, attrs, ext.CoreID.Idx, ch)
+	if err != nil {
+		return count, &dcfile.ErrExtensionRead{Err: err}
+	}
+	return count, nil
+}
+
+// sortedStream reads rows of CSV files and sends them to the channel
+// sorted by the field with keyIdx. Rows are sorted in chunks, if there
+// is more than one chunk, they are saved to temporary files and merged.
+func (d *dcfileio) sortedStream(
+	ctx context.Context,
+	attrs []ent.CSVAttr,
+	keyIdx int,
+	ch chan<- dcfile.Row,
+) (int, error) {
+	dir, err := os.MkdirTemp(d.cfg.WorkPath, "sort-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	chRow := make(chan []string)
+	g, gctx := errgroup.WithContext(ctx)
+
+	var count int
+	g.Go(func() error {
+		defer close(chRow)
+		var err error
+		count, err = readStream(gctx, attrs, chRow)
+		return err
+	})
+
+	var chunk []dcfile.Row
+	var runs []string
+	g.Go(func() error {
+		var num int
+		for row := range chRow {
+			num++
+			chunk = append(chunk, dcfile.Row{RowNum: num, Values: row})
+			if len(chunk) < sortChunkSize {
+				continue
+			}
+			path, err := saveRun(dir, len(runs), chunk, keyIdx)
+			if err != nil {
+				for range chRow {
+				}
+				return err
+			}
+			runs = append(runs, path)
+			chunk = chunk[:0]
+		}
+		return nil
+	})
+
+	err = g.Wait()
+	if err != nil {
+		return count, err
+	}
+
+	// all rows fit into one chunk.
+	if len(runs) == 0 {
+		sortRows(chunk, keyIdx)
+		for _, v := range chunk {
+			select {
+			case <-ctx.Done():
+				return count, &dcfile.ErrContext{Err: ctx.Err()}
+			case ch <- v:
+			}
+		}
+		return count, nil
+	}
+
+	if len(chunk) > 0 {
+		path, err := saveRun(dir, len(runs), chunk, keyIdx)
+		if err != nil {
+			return count, err
+		}
+		runs = append(runs, path)
+	}
+	return count, mergeRuns(ctx, runs, keyIdx, ch)
+}
+
+func sortRows(rows []dcfile.Row, keyIdx int) {
+	slices.SortStableFunc(rows, func(a, b dcfile.Row) int {
+		return cmp.Compare(rowKey(a, keyIdx), rowKey(b, keyIdx))
+	})
+}
+
+func rowKey(row dcfile.Row, keyIdx int) string {
+	if keyIdx < 0 || keyIdx >= len(row.Values) {
+		return ""
+	}
+	return row.Values[keyIdx]
+}
+
+// saveRun sorts rows and saves them to a temporary file.
+func saveRun(
+	dir string,
+	num int,
+	rows []dcfile.Row,
+	keyIdx int,
+) (string, error) {
+	sortRows(rows, keyIdx)
+	path := filepath.Join(dir, fmt.Sprintf("run-%d.gob", num))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	for _, v := range rows {
+		err = enc.Encode(v)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// run is a sorted temporary file that is read during merging.
+type run struct {
+	num  int
+	row  dcfile.Row
+	key  string
+	file *os.File
+	dec  *gob.Decoder
+}
+
+// next reads the next row of the run. It returns false if the run is
+// exhausted.
+func (r *run) next(keyIdx int) (bool, error) {
+	var row dcfile.Row
+	err := r.dec.Decode(&row)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.row = row
+	r.key = rowKey(row, keyIdx)
+	return true, nil
+}
+
+// runHeap keeps runs ordered by their current rows. Runs with the same
+// key are ordered by their numbers, so the merge is stable.
+type runHeap []*run
+
+func (h runHeap) Len() int { return len(h) }
+
+func (h runHeap) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key < h[j].key
+	}
+	return h[i].num < h[j].num
+}
+
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x any) { *h = append(*h, x.(*run)) }
+
+func (h *runHeap) Pop() any {
+	old := *h
+	n := len(old)
+	res := old[n-1]
+	*h = old[:n-1]
+	return res
+}
+
+// mergeRuns merges sorted temporary files and sends their rows to the
+// channel.
+func mergeRuns(
+	ctx context.Context,
+	paths []string,
+	keyIdx int,
+	ch chan<- dcfile.Row,
+) error {
+	h := make(runHeap, 0, len(paths))
+	defer func() {
+		for _, v := range h {
+			v.file.Close()
+		}
+	}()
+
+	for i, v := range paths {
+		f, err := os.Open(v)
+		if err != nil {
+			return err
+		}
+		r := &run{num: i, file: f, dec: gob.NewDecoder(bufio.NewReader(f))}
+		ok, err := r.next(keyIdx)
+		if err != nil || !ok {
+			f.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		h = append(h, r)
+	}
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		r := h[0]
+		select {
+		case <-ctx.Done():
+			return &dcfile.ErrContext{Err: ctx.Err()}
+		case ch <- r.row:
+		}
+
+		ok, err := r.next(keyIdx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+			continue
+		}
+		heap.Pop(&h)
+		r.file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package dcfileio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedStream(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	var sb strings.Builder
+	sb.WriteString("id,val\n")
+	rowsNum := 1000
+	for i := range rowsNum {
+		fmt.Fprintf(&sb, "k%02d,%d\n", (i*37)%50, i)
+	}
+	err := os.WriteFile(filepath.Join(dir, "data.csv"), []byte(sb.String()), 0644)
+	assert.Nil(err)
+
+	attrs := []ent.CSVAttr{{
+		Path:         "data.csv",
+		FS:           os.DirFS(dir),
+		ColSep:       ',',
+		IgnoreHeader: "1",
+	}}
+	d := &dcfileio{cfg: config.New(config.OptRootPath(dir))}
+
+	tests := []struct {
+		msg   string
+		chunk int
+	}{
+		{"memory", 100_000},
+		{"one run", rowsNum},
+		{"runs", 7},
+	}
+	for _, v := range tests {
+		sortChunkSize = v.chunk
+		ch := make(chan dcfile.Row)
+		var rows []dcfile.Row
+		done := make(chan struct{})
+		go func() {
+			for row := range ch {
+				rows = append(rows, row)
+			}
+			close(done)
+		}()
+		count, err := d.sortedStream(context.Background(), attrs, 0, ch)
+		close(ch)
+		<-done
+		assert.Nil(err, v.msg)
+		assert.Equal(rowsNum, count, v.msg)
+		assert.Equal(rowsNum, len(rows), v.msg)
+
+		for i := 1; i < len(rows); i++ {
+			prev, cur := rows[i-1], rows[i]
+			assert.LessOrEqual(prev.Values[0], cur.Values[0], v.msg)
+			if prev.Values[0] == cur.Values[0] {
+				// sorting is stable.
+				assert.Less(prev.RowNum, cur.RowNum, v.msg)
+			}
+			assert.Equal(fmt.Sprintf("%d", cur.RowNum-1), cur.Values[1], v.msg)
+		}
+
+		// temporary files are removed.
+		es, err := filepath.Glob(filepath.Join(dir, "sort-*"))
+		assert.Nil(err)
+		assert.Empty(es, v.msg)
+	}
+	sortChunkSize = 100_000
+}
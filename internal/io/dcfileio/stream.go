@@ -0,0 +1,122 @@
+package dcfileio
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/gnames/dwca/internal/ent/dcfile"
+)
+
+// openFS prepares reading of the archive without extraction.
+func (d *dcfileio) openFS() error {
+	var fsys fs.FS
+	var dir string
+	var err error
+
+	switch d.fileType {
+	case dcfile.ZIP:
+		var zr *zip.ReadCloser
+		zr, err = zip.OpenReader(d.filePath)
+		if err != nil {
+			return err
+		}
+		fsys = zr
+		d.closer = zr
+		dir, err = fsArchiveDir(zr)
+	case dcfile.DIR:
+		fsys = os.DirFS(d.filePath)
+		dir, err = fsArchiveDir(fsys)
+	case dcfile.FS:
+		fsys = d.src
+		dir, err = fsArchiveDir(fsys)
+	case dcfile.TAR, dcfile.TARGZ, dcfile.TARBZ2, dcfile.TARXZ:
+		tfs := &tarFS{path: d.filePath, fileType: d.fileType}
+		fsys = tfs
+		dir, err = tarArchiveDir(tfs)
+	default:
+		return &dcfile.ErrUnknownArchiveType{FileType: d.fileType}
+	}
+
+	if err != nil {
+		d.closeFS()
+		return err
+	}
+	d.fsys = fsys
+	d.fsDir = dir
+	return nil
+}
+
+// closeFS releases resources used for reading the archive without
+// extraction.
+func (d *dcfileio) closeFS() error {
+	d.fsys = nil
+	d.fsDir = ""
+	if d.closer == nil {
+		return nil
+	}
+	err := d.closer.Close()
+	d.closer = nil
+	return err
+}
+
+// FS returns the file system with DwCA files and the directory inside of
+// it where meta.xml is located.
+func (d *dcfileio) FS(root string) (fs.FS, string, error) {
+	if d.fsys != nil && root == d.cfg.ExtractPath {
+		return d.fsys, d.fsDir, nil
+	}
+	dir, err := d.ArchiveDir(root)
+	if err != nil {
+		return nil, "", err
+	}
+	return os.DirFS(dir), ".", nil
+}
+
+// fsArchiveDir finds the directory with meta.xml file in a file system.
+func fsArchiveDir(fsys fs.FS) (string, error) {
+	var dirs []string
+	err := fs.WalkDir(fsys, ".",
+		func(name string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !e.IsDir() && e.Name() == "meta.xml" {
+				dirs = append(dirs, path.Dir(name))
+			}
+			return nil
+		})
+	if err != nil {
+		return "", err
+	}
+	return metaDir(dirs)
+}
+
+// tarArchiveDir finds the directory with meta.xml file in a TAR archive.
+// To avoid reading the whole archive, the search stops at the first
+// meta.xml file.
+func tarArchiveDir(t *tarFS) (string, error) {
+	var dirs []string
+	err := t.walk(func(name string) bool {
+		if path.Base(name) == "meta.xml" {
+			dirs = append(dirs, path.Dir(name))
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	return metaDir(dirs)
+}
+
+func metaDir(dirs []string) (string, error) {
+	if len(dirs) == 0 {
+		return "", &dcfile.ErrMetaFileNotFound{}
+	}
+	if len(dirs) > 1 {
+		return "", &dcfile.ErrMultipleMetaFiles{}
+	}
+	return dirs[0], nil
+}
@@ -0,0 +1,252 @@
+package dcfileio
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/ulikunitz/xz"
+)
+
+// tarFS is a read-only file system on top of a TAR archive, that might be
+// compressed. Headers of all files are indexed during the first pass
+// through the archive, so Stat and search of files do not read the archive
+// again. Data of uncompressed archives are read from their offsets,
+// compressed archives are read from the start until the file is found.
+type tarFS struct {
+	path     string
+	fileType dcfile.FileType
+
+	// once makes sure the index of files is created only once.
+	once sync.Once
+	// files are regular files of the archive by their cleaned names.
+	files map[string]tarEntry
+	// names are names of the files in the order of the archive.
+	names []string
+	// idxErr is the error of indexing.
+	idxErr error
+}
+
+// tarEntry is an indexed regular file of a TAR archive.
+type tarEntry struct {
+	info fs.FileInfo
+	// offset is the position of the file data in uncompressed TAR archives.
+	// It is -1 if the data cannot be read directly.
+	offset int64
+}
+
+// tarFile is a file inside of a TAR archive.
+type tarFile struct {
+	io.Reader
+	info    fs.FileInfo
+	closers []io.Closer
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *tarFile) Close() error {
+	var err error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		if cErr := f.closers[i].Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// Open finds a regular file in the archive.
+func (t *tarFS) Open(name string) (fs.File, error) {
+	entry, err := t.entry("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.offset >= 0 {
+		return t.openAt(name, entry)
+	}
+
+	tr, closers, err := t.reader()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	f := &tarFile{Reader: tr, closers: closers, info: entry.info}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if err != nil {
+			f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Clean(hdr.Name) == name {
+			return f, nil
+		}
+	}
+}
+
+// openAt opens a file of an uncompressed archive at its offset.
+func (t *tarFS) openAt(name string, entry tarEntry) (fs.File, error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	_, err = file.Seek(entry.offset, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &tarFile{
+		Reader:  io.LimitReader(file, entry.info.Size()),
+		info:    entry.info,
+		closers: []io.Closer{file},
+	}, nil
+}
+
+// Stat returns information about a regular file in the archive without
+// reading the archive.
+func (t *tarFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := t.entry("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return entry.info, nil
+}
+
+// entry finds an indexed file by its name.
+func (t *tarFS) entry(op, name string) (tarEntry, error) {
+	if !fs.ValidPath(name) {
+		return tarEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	err := t.index()
+	if err != nil {
+		return tarEntry{}, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	entry, ok := t.files[name]
+	if !ok {
+		return tarEntry{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// walk calls fn for the names of all regular files in the archive until
+// fn returns false.
+func (t *tarFS) walk(fn func(name string) bool) error {
+	err := t.index()
+	if err != nil {
+		return err
+	}
+	for _, v := range t.names {
+		if !fn(v) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// index reads headers of all regular files of the archive once.
+func (t *tarFS) index() error {
+	t.once.Do(func() {
+		t.idxErr = t.readIndex()
+	})
+	return t.idxErr
+}
+
+func (t *tarFS) readIndex() error {
+	tr, closers, err := t.reader()
+	if err != nil {
+		return err
+	}
+	f := &tarFile{closers: closers}
+	defer f.Close()
+
+	// data of uncompressed archives can be found by their position in the
+	// file.
+	var seeker io.Seeker
+	if t.fileType == dcfile.TAR {
+		seeker, _ = closers[0].(io.Seeker)
+	}
+
+	t.files = make(map[string]tarEntry)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		// Open finds the first file with the name.
+		if _, ok := t.files[name]; ok || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		entry := tarEntry{info: hdr.FileInfo(), offset: -1}
+		if seeker != nil && !isSparse(hdr) {
+			entry.offset, err = seeker.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+		}
+		t.names = append(t.names, name)
+		t.files[name] = entry
+	}
+}
+
+// isSparse checks if a file is saved in sparse format, so its data cannot
+// be read directly.
+func isSparse(hdr *tar.Header) bool {
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// reader opens the archive and returns a TAR reader together with objects
+// that have to be closed after reading.
+func (t *tarFS) reader() (*tar.Reader, []io.Closer, error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	closers := []io.Closer{file}
+
+	var r io.Reader
+	switch t.fileType {
+	case dcfile.TAR:
+		r = file
+	case dcfile.TARGZ:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		closers = append(closers, gz)
+		r = gz
+	case dcfile.TARBZ2:
+		r = bzip2.NewReader(file)
+	case dcfile.TARXZ:
+		r, err = xz.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+	default:
+		file.Close()
+		return nil, nil, &dcfile.ErrUnknownArchiveType{FileType: t.fileType}
+	}
+	return tar.NewReader(r), closers, nil
+}
@@ -0,0 +1,93 @@
+package dcfileio
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarFS(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	files := []struct{ name, body string }{
+		{"dwca/meta.xml", "<archive/>"},
+		{"dwca/taxon.txt", "taxonID\n1\n2\n"},
+		{"dwca/vernacular.txt", "coreID\tvernacularName\n1\tpine\n"},
+	}
+
+	write := func(w io.Writer) {
+		tw := tar.NewWriter(w)
+		for _, v := range files {
+			hdr := &tar.Header{
+				Name:     v.name,
+				Mode:     0644,
+				Size:     int64(len(v.body)),
+				Typeflag: tar.TypeReg,
+			}
+			assert.Nil(tw.WriteHeader(hdr))
+			_, err := tw.Write([]byte(v.body))
+			assert.Nil(err)
+		}
+		assert.Nil(tw.Close())
+	}
+
+	tarPath := filepath.Join(dir, "data.tar")
+	f, err := os.Create(tarPath)
+	assert.Nil(err)
+	write(f)
+	assert.Nil(f.Close())
+
+	gzPath := filepath.Join(dir, "data.tar.gz")
+	f, err = os.Create(gzPath)
+	assert.Nil(err)
+	gz := gzip.NewWriter(f)
+	write(gz)
+	assert.Nil(gz.Close())
+	assert.Nil(f.Close())
+
+	tests := []struct {
+		msg      string
+		path     string
+		fileType dcfile.FileType
+		direct   bool
+	}{
+		{"tar", tarPath, dcfile.TAR, true},
+		{"tar.gz", gzPath, dcfile.TARGZ, false},
+	}
+
+	for _, v := range tests {
+		tfs := &tarFS{path: v.path, fileType: v.fileType}
+		arcDir, err := tarArchiveDir(tfs)
+		assert.Nil(err, v.msg)
+		assert.Equal("dwca", arcDir, v.msg)
+
+		// headers are indexed during the first pass.
+		for _, e := range tfs.files {
+			assert.Equal(v.direct, e.offset >= 0, v.msg)
+		}
+		assert.Equal(len(files), len(tfs.names), v.msg)
+
+		for _, file := range files {
+			info, err := fs.Stat(tfs, file.name)
+			assert.Nil(err, v.msg)
+			assert.Equal(int64(len(file.body)), info.Size(), v.msg)
+
+			bs, err := fs.ReadFile(tfs, file.name)
+			assert.Nil(err, v.msg)
+			assert.Equal(file.body, string(bs), v.msg)
+		}
+
+		_, err = fs.Stat(tfs, "dwca/distribution.txt")
+		assert.True(errors.Is(err, fs.ErrNotExist), v.msg)
+		_, err = tfs.Open("dwca/distribution.txt")
+		assert.True(errors.Is(err, fs.ErrNotExist), v.msg)
+	}
+}
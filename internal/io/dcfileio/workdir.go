@@ -0,0 +1,117 @@
+package dcfileio
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/gnsys"
+)
+
+const (
+	// workDirPrefix is the prefix of working directories of archives.
+	workDirPrefix = "arc-"
+	// lockFile marks a working directory as used by a running process.
+	// It contains the process ID.
+	lockFile = ".lock"
+)
+
+// NewWorkDir creates a unique working directory inside of cfg.RootPath and
+// returns a configuration that uses it for downloaded, extracted and output
+// files. The directory is locked by the current process until it is
+// removed by Close. Working directories left by processes that are not
+// running anymore are removed.
+func NewWorkDir(cfg config.Config) (config.Config, error) {
+	err := gnsys.MakeDir(cfg.RootPath)
+	if err != nil {
+		return cfg, err
+	}
+	cleanStaleWorkDirs(cfg.RootPath)
+
+	dir, err := os.MkdirTemp(cfg.RootPath, workDirPrefix)
+	if err != nil {
+		return cfg, err
+	}
+
+	err = lock(dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return cfg, err
+	}
+	return cfg.WithWorkPath(dir), nil
+}
+
+// isWorkDir checks if the configuration uses its own working directory.
+func isWorkDir(cfg config.Config) bool {
+	return cfg.WorkPath != "" && cfg.WorkPath != cfg.RootPath
+}
+
+// lock creates a lock file with the current process ID in the directory.
+func lock(dir string) error {
+	path := filepath.Join(dir, lockFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strconv.Itoa(os.Getpid()))
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// isLocked checks if the directory is locked by a running process.
+// Directories without a lock file are considered locked, because they
+// might be just created.
+func isLocked(dir string) bool {
+	bs, err := os.ReadFile(filepath.Join(dir, lockFile))
+	if err != nil {
+		return !errors.Is(err, fs.ErrNotExist)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(bs)))
+	if err != nil {
+		return false
+	}
+	return processRuns(pid)
+}
+
+// processRuns checks if a process with the given ID is running.
+func processRuns(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) &&
+		!errors.Is(err, syscall.ESRCH)
+}
+
+// cleanStaleWorkDirs removes working directories that are not locked.
+func cleanStaleWorkDirs(root string) {
+	es, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range es {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), workDirPrefix) {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		if isLocked(dir) {
+			continue
+		}
+		slog.Info("Removing stale working directory", "path", dir)
+		if err = os.RemoveAll(dir); err != nil {
+			slog.Warn("Cannot remove working directory", "path", dir, "error", err)
+		}
+	}
+}
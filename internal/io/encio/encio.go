@@ -0,0 +1,114 @@
+// package encio converts text in different encodings to UTF-8.
+package encio
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// NewReader returns a reader that converts text from the given encoding
+// to UTF-8. Byte order marks are removed. If a file starts with a UTF-16
+// byte order mark, it is decoded as UTF-16 regardless of the given
+// encoding. Empty or unknown encodings are treated as UTF-8.
+func NewReader(r io.Reader, enc string) io.Reader {
+	return transform.NewReader(r, unicode.BOMOverride(decoder(enc)))
+}
+
+// NewReaderAt works like NewReader, but the text starts at the given
+// offset of the UTF-8 data. Files in UTF-8 that support seeking are not
+// read up to the offset, for other files the text before the offset is
+// converted and discarded. Callers that read such files from offsets
+// many times should read a UTF-8 copy instead.
+func NewReaderAt(r io.Reader, enc string, offset int64) (io.Reader, error) {
+	if offset == 0 {
+		return NewReader(r, enc), nil
+	}
+
+	if s, ok := r.(io.ReadSeeker); ok && Encoding(enc) == unicode.UTF8 {
+		start, err := utf8Start(s)
+		if err != nil {
+			return nil, err
+		}
+		if start >= 0 {
+			_, err = s.Seek(start+offset, io.SeekStart)
+			if err != nil {
+				return nil, err
+			}
+			return s, nil
+		}
+	}
+
+	res := NewReader(r, enc)
+	_, err := io.CopyN(io.Discard, res, offset)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// utf8Start returns the size of UTF-8 byte order mark at the start of
+// the data. It returns -1 if the data starts with UTF-16 byte order mark,
+// and the data cannot be read as UTF-8.
+func utf8Start(s io.ReadSeeker) (int64, error) {
+	_, err := s.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	bs := make([]byte, 3)
+	n, err := io.ReadFull(s, bs)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, err
+	}
+	bs = bs[:n]
+
+	switch {
+	case bytes.HasPrefix(bs, []byte("\xef\xbb\xbf")):
+		return 3, nil
+	case bytes.HasPrefix(bs, []byte("\xff\xfe")),
+		bytes.HasPrefix(bs, []byte("\xfe\xff")):
+		if _, err = s.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		return -1, nil
+	}
+	return 0, nil
+}
+
+func decoder(enc string) transform.Transformer {
+	e := Encoding(enc)
+	if e == unicode.UTF8 {
+		// keep UTF-8 as is, only BOM is removed.
+		return transform.Nop
+	}
+	return e.NewDecoder()
+}
+
+// Encoding finds an encoding by its name, for example "UTF-8",
+// "windows-1252", "ISO-8859-1" or "UTF-16". Empty or unknown names
+// return UTF-8.
+func Encoding(name string) encoding.Encoding {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return unicode.UTF8
+	}
+	switch strings.ToLower(name) {
+	case "utf8", "utf-8":
+		return unicode.UTF8
+	case "utf16", "utf-16":
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	}
+
+	e, err := htmlindex.Get(name)
+	if err != nil {
+		slog.Warn("Unknown encoding, using UTF-8", "encoding", name)
+		return unicode.UTF8
+	}
+	return e
+}
@@ -0,0 +1,59 @@
+package encio_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/gnames/dwca/internal/io/encio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReader(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		msg, enc string
+		in       []byte
+		out      string
+	}{
+		{"utf8", "UTF-8", []byte("Linné"), "Linné"},
+		{"empty", "", []byte("Linné"), "Linné"},
+		{"utf8 bom", "UTF-8", []byte("\xef\xbb\xbfLinné"), "Linné"},
+		{"latin1", "ISO-8859-1", []byte("Linn\xe9"), "Linné"},
+		{"cp1252", "windows-1252", []byte("\x93Linn\xe9\x94"), "“Linné”"},
+		{"cp1252 alias", "cp1252", []byte("Linn\xe9"), "Linné"},
+		{"utf16le bom", "UTF-16", []byte("\xff\xfeL\x00\xe9\x00"), "Lé"},
+		{"utf16be bom", "UTF-16", []byte("\xfe\xff\x00L\x00\xe9"), "Lé"},
+		{"utf16 bom undeclared", "", []byte("\xff\xfeL\x00\xe9\x00"), "Lé"},
+		{"unknown", "klingon", []byte("Linné"), "Linné"},
+	}
+	for _, v := range tests {
+		r := encio.NewReader(bytes.NewReader(v.in), v.enc)
+		res, err := io.ReadAll(r)
+		assert.Nil(err, v.msg)
+		assert.Equal(v.out, string(res), v.msg)
+	}
+}
+
+func TestNewReaderAt(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		msg, enc string
+		in       []byte
+		offset   int64
+		out      string
+	}{
+		{"start", "UTF-8", []byte("Linné\nCarl"), 0, "Linné\nCarl"},
+		{"utf8", "UTF-8", []byte("Linné\nCarl"), 7, "Carl"},
+		{"utf8 bom", "", []byte("\xef\xbb\xbfLinné\nCarl"), 7, "Carl"},
+		{"latin1", "ISO-8859-1", []byte("Linn\xe9\nCarl"), 7, "Carl"},
+		{"utf16 bom", "", []byte("\xff\xfeL\x00\xe9\x00\n\x00C\x00"), 4, "C"},
+	}
+	for _, v := range tests {
+		r, err := encio.NewReaderAt(bytes.NewReader(v.in), v.enc, v.offset)
+		assert.Nil(err, v.msg)
+		res, err := io.ReadAll(r)
+		assert.Nil(err, v.msg)
+		assert.Equal(v.out, string(res), v.msg)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gnames/dwca/internal/ent"
 	"github.com/gnames/dwca/internal/io/csvnio"
 	"github.com/gnames/dwca/internal/io/csvsio"
+	"github.com/gnames/dwca/internal/io/sqlio"
 )
 
 func CSVReader(attr ent.CSVAttr) (ent.CSVReader, error) {
@@ -29,3 +30,11 @@ func CSVWriter(attr ent.CSVAttr) (ent.CSVWriter, error) {
 	}
 	return res, nil
 }
+
+func SQLWriter(path string) (ent.SQLWriter, error) {
+	res, err := sqlio.New(path)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,144 @@
+// package sqlio saves data to SQLite databases. It uses a pure-Go SQLite
+// driver, so it does not need CGO.
+package sqlio
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	_ "modernc.org/sqlite"
+)
+
+type sqlio struct {
+	db *sql.DB
+}
+
+// New creates a new SQLite database at the given path. An existing file
+// is replaced.
+func New(path string) (ent.SQLWriter, error) {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// PRAGMAs are set per connection, so all statements have to use the
+	// same one.
+	db.SetMaxOpenConns(1)
+
+	// the database is created from scratch, so the journal is not needed.
+	for _, v := range []string{
+		"PRAGMA journal_mode = OFF",
+		"PRAGMA synchronous = OFF",
+	} {
+		if _, err = db.Exec(v); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &sqlio{db: db}, nil
+}
+
+func (s *sqlio) CreateTable(t ent.SQLTable) error {
+	cols := make([]string, len(t.Columns))
+	for i, v := range t.Columns {
+		cols[i] = quote(v) + " TEXT"
+	}
+	q := fmt.Sprintf("CREATE TABLE %s (%s)", quote(t.Name), strings.Join(cols, ", "))
+	_, err := s.db.Exec(q)
+	return err
+}
+
+func (s *sqlio) Write(
+	ctx context.Context,
+	t ent.SQLTable,
+	ch <-chan []string,
+) (int, error) {
+	var count int
+	err := s.write(ctx, t, ch, &count)
+	if err != nil {
+		for range ch {
+		}
+		return count, err
+	}
+	return count, nil
+}
+
+func (s *sqlio) write(
+	ctx context.Context,
+	t ent.SQLTable,
+	ch <-chan []string,
+	count *int,
+) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cols := make([]string, len(t.Columns))
+	params := make([]string, len(t.Columns))
+	for i, v := range t.Columns {
+		cols[i] = quote(v)
+		params[i] = "?"
+	}
+	q := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		quote(t.Name), strings.Join(cols, ", "), strings.Join(params, ", "),
+	)
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]any, len(t.Columns))
+	for row := range ch {
+		for i := range args {
+			args[i] = ""
+			if i < len(row) {
+				args[i] = row[i]
+			}
+		}
+		_, err = stmt.ExecContext(ctx, args...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return &dcfile.ErrContext{Err: ctx.Err()}
+			}
+			return err
+		}
+		*count++
+	}
+	return tx.Commit()
+}
+
+func (s *sqlio) CreateIndices(t ent.SQLTable) error {
+	for _, v := range t.Indices {
+		q := fmt.Sprintf(
+			"CREATE INDEX %s ON %s (%s)",
+			quote(t.Name+"_"+v+"_idx"), quote(t.Name), quote(v),
+		)
+		_, err := s.db.Exec(q)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlio) Close() error {
+	return s.db.Close()
+}
+
+// quote makes an SQL identifier out of a name.
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
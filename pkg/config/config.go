@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/gnames/gnfmt"
 )
@@ -12,6 +13,9 @@ var (
 	outputCompression = "zip"
 	outputCSVType     = "csv"
 	jobsNum           = 5
+	diagnSampleSize   = 1000
+	diagnMode         = "head"
+	extErrorPolicy    = "abort"
 )
 
 // Config is a configuration object for the Darwin Core Archive (DwCA)
@@ -20,12 +24,28 @@ type Config struct {
 	// RootPath is the root path for all temporary files.
 	RootPath string
 
+	// WorkPath is the working directory of an archive. By default it is
+	// the same as RootPath. With IsolateWorkDir archives created by
+	// factories get a unique working directory inside of RootPath.
+	WorkPath string
+
+	// IsolateWorkDir gives every archive created by a factory its own
+	// locked working directory inside of RootPath, so several archives
+	// can be processed at the same time in one or more processes. The
+	// directory is removed by Close. Use Config method of the archive to
+	// find its extract and output directories.
+	IsolateWorkDir bool
+
 	// DownloadPath is used to store downloaded files.
 	DownloadPath string
 
 	// ExtractPath is used to store extracted files of DwCA archive.
 	ExtractPath string
 
+	// CachePath is used to store extracted files and normalized output of
+	// archives by their SHA-256 checksum. It is located in RootPath.
+	CachePath string
+
 	// OutputPath is used to store uncompressed files of a normalized
 	// DwCA archive. This files are created from the original DwCA archive
 	// data.
@@ -43,6 +63,52 @@ type Config struct {
 
 	// WithSloppyCSV allows to have more fields in a row, than it should have.
 	WrongFieldsNum gnfmt.BadRow
+
+	// DiagnSampleSize is the number of Core rows that are used to detect
+	// how names, synonyms and hierarchy are represented in the archive.
+	DiagnSampleSize int
+
+	// DiagnMode determines which Core rows are used for diagnostics.
+	// It can be "head" (the first DiagnSampleSize rows), "stratified"
+	// (DiagnSampleSize rows evenly spread through the whole file) or "full"
+	// (all rows).
+	DiagnMode string
+
+	// ExtErrorPolicy determines what happens when an extension cannot be
+	// processed during normalization. With "abort" normalization stops
+	// with an error. With "continue" other extensions are processed, the
+	// failed ones are excluded from the output, and their errors are
+	// returned after normalization is finished. Extensions with missing
+	// data files fail the same way.
+	ExtErrorPolicy string
+
+	// StreamArchive allows to read data files directly from ZIP and TAR
+	// archives without extracting them to ExtractPath. If an archive
+	// cannot be read this way, it is extracted.
+	StreamArchive bool
+
+	// UseCache allows to reuse extracted files and normalized output of
+	// archives that were processed before. Archives are identified by
+	// their SHA-256 checksum, so a changed archive is processed again.
+	UseCache bool
+
+	// IndexData builds an index of Core and Extension rows when an archive
+	// is loaded. The index allows to find records by their IDs, and to read
+	// slices of data without scanning files from the start. Without this
+	// option the index is built when a record is requested by its ID for
+	// the first time. Data files that are not in UTF-8, or cannot be read
+	// from an arbitrary position, are copied to the index as UTF-8 text.
+	IndexData bool
+
+	// CacheMaxSize is the maximum size of the cache in bytes. When it is
+	// exceeded, least recently used archives are removed from the cache.
+	// Zero means no limit.
+	CacheMaxSize int64
+
+	// CacheMaxAge is the maximum time since the last use of a cached
+	// archive, older archives are removed from the cache. Zero means
+	// no limit.
+	CacheMaxAge time.Duration
 }
 
 // Option is a function type that allows to standardize how options to
@@ -99,6 +165,107 @@ func OptJobsNum(i int) Option {
 	}
 }
 
+// OptDiagnSampleSize sets the number of Core rows used for diagnostics.
+func OptDiagnSampleSize(i int) Option {
+	return func(c *Config) {
+		if i < 1 {
+			slog.Warn(
+				"Sample size must be positive. Using default value",
+				"bad-input", i, "default", diagnSampleSize,
+			)
+			i = diagnSampleSize
+		}
+		c.DiagnSampleSize = i
+	}
+}
+
+// OptDiagnMode sets which Core rows are used for diagnostics. It can be
+// "head", "stratified" or "full".
+func OptDiagnMode(s string) Option {
+	return func(c *Config) {
+		if s != "head" && s != "stratified" && s != "full" {
+			slog.Warn(
+				"Entered diagnostics mode is not supported. Using default mode",
+				"bad-input", s, "default", diagnMode,
+			)
+			s = diagnMode
+		}
+		c.DiagnMode = s
+	}
+}
+
+// OptExtErrorPolicy sets what happens when an extension cannot be
+// processed. It can be "abort" or "continue".
+func OptExtErrorPolicy(s string) Option {
+	return func(c *Config) {
+		if s != "abort" && s != "continue" {
+			slog.Warn(
+				"Entered extension error policy is not supported. Using default",
+				"bad-input", s, "default", extErrorPolicy,
+			)
+			s = extErrorPolicy
+		}
+		c.ExtErrorPolicy = s
+	}
+}
+
+// OptStreamArchive sets reading of data files directly from compressed
+// archives, without extraction.
+func OptStreamArchive(b bool) Option {
+	return func(c *Config) {
+		c.StreamArchive = b
+	}
+}
+
+// OptUseCache sets reuse of extracted files and normalized output of
+// unchanged archives.
+func OptUseCache(b bool) Option {
+	return func(c *Config) {
+		c.UseCache = b
+	}
+}
+
+// OptIsolateWorkDir sets creation of a unique working directory for
+// every archive.
+func OptIsolateWorkDir(b bool) Option {
+	return func(c *Config) {
+		c.IsolateWorkDir = b
+	}
+}
+
+// OptIndexData sets building of the index of data rows during loading
+// of an archive.
+func OptIndexData(b bool) Option {
+	return func(c *Config) {
+		c.IndexData = b
+	}
+}
+
+// OptCacheMaxSize sets the maximum size of the cache in bytes.
+func OptCacheMaxSize(i int64) Option {
+	return func(c *Config) {
+		if i < 0 {
+			slog.Warn("Cache size cannot be negative, cache size is not limited",
+				"bad-input", i)
+			i = 0
+		}
+		c.CacheMaxSize = i
+	}
+}
+
+// OptCacheMaxAge sets the maximum time since the last use of a cached
+// archive.
+func OptCacheMaxAge(d time.Duration) Option {
+	return func(c *Config) {
+		if d < 0 {
+			slog.Warn("Cache age cannot be negative, cache age is not limited",
+				"bad-input", d)
+			d = 0
+		}
+		c.CacheMaxAge = d
+	}
+}
+
 func OptWrongFieldsNum(br gnfmt.BadRow) Option {
 	return func(c *Config) {
 		c.WrongFieldsNum = br
@@ -120,14 +287,25 @@ func New(opts ...Option) Config {
 		OutputCSVType:            outputCSVType,
 		JobsNum:                  jobsNum,
 		WrongFieldsNum:           gnfmt.ErrorBadRow,
+		DiagnSampleSize:          diagnSampleSize,
+		DiagnMode:                diagnMode,
+		ExtErrorPolicy:           extErrorPolicy,
 	}
 
 	for _, opt := range opts {
 		opt(&c)
 	}
 
-	c.DownloadPath = filepath.Join(c.RootPath, "download")
-	c.ExtractPath = filepath.Join(c.RootPath, "extract")
-	c.OutputPath = filepath.Join(c.RootPath, "output")
+	c.CachePath = filepath.Join(c.RootPath, "cache")
+	return c.WithWorkPath(c.RootPath)
+}
+
+// WithWorkPath returns a copy of the configuration that keeps downloaded,
+// extracted and output files in the given working directory.
+func (c Config) WithWorkPath(path string) Config {
+	c.WorkPath = path
+	c.DownloadPath = filepath.Join(path, "download")
+	c.ExtractPath = filepath.Join(path, "extract")
+	c.OutputPath = filepath.Join(path, "output")
 	return c
 }
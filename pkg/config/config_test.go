@@ -18,3 +18,36 @@ func TestConfigDefault(t *testing.T) {
 	conf = config.New(opts...)
 	assert.Equal("test", conf.RootPath)
 }
+
+func TestConfigDiagn(t *testing.T) {
+	assert := assert.New(t)
+	conf := config.New()
+	assert.Equal(1000, conf.DiagnSampleSize)
+	assert.Equal("head", conf.DiagnMode)
+
+	conf = config.New(
+		config.OptDiagnSampleSize(50),
+		config.OptDiagnMode("stratified"),
+	)
+	assert.Equal(50, conf.DiagnSampleSize)
+	assert.Equal("stratified", conf.DiagnMode)
+
+	conf = config.New(
+		config.OptDiagnSampleSize(0),
+		config.OptDiagnMode("random"),
+	)
+	assert.Equal(1000, conf.DiagnSampleSize)
+	assert.Equal("head", conf.DiagnMode)
+}
+
+func TestConfigExtErrorPolicy(t *testing.T) {
+	assert := assert.New(t)
+	conf := config.New()
+	assert.Equal("abort", conf.ExtErrorPolicy)
+
+	conf = config.New(config.OptExtErrorPolicy("continue"))
+	assert.Equal("continue", conf.ExtErrorPolicy)
+
+	conf = config.New(config.OptExtErrorPolicy("ignore"))
+	assert.Equal("abort", conf.ExtErrorPolicy)
+}
@@ -0,0 +1,371 @@
+package dwca
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gnames/dwca/pkg/ent/meta"
+)
+
+// eventInherited contains lowercased terms of fields that child events and
+// occurrences inherit from their parent events, if the fields are empty.
+var eventInherited = []string{
+	"locationid",
+	"highergeography",
+	"continent",
+	"waterbody",
+	"island",
+	"country",
+	"countrycode",
+	"stateprovince",
+	"county",
+	"municipality",
+	"locality",
+	"minimumelevationinmeters",
+	"maximumelevationinmeters",
+	"decimallatitude",
+	"decimallongitude",
+	"geodeticdatum",
+	"coordinateuncertaintyinmeters",
+	"eventdate",
+	"year",
+	"month",
+	"day",
+	"habitat",
+}
+
+// eventFieldGroups contains lowercased terms of inheritable fields that are
+// inherited together. A group is inherited only if all its fields are
+// empty, so coordinates and dates are not mixed from different events.
+var eventFieldGroups = [][]string{
+	{
+		"decimallatitude",
+		"decimallongitude",
+		"geodeticdatum",
+		"coordinateuncertaintyinmeters",
+	},
+	{"eventdate", "year", "month", "day"},
+}
+
+// eventNode contains data of an Event core record that is needed to
+// resolve inherited fields.
+type eventNode struct {
+	parentID string
+	// values are indexed the same way as eventFields.
+	values []string
+}
+
+// eventField is a field of the Event core that can be inherited.
+type eventField struct {
+	term     string
+	termFull string
+	index    int
+}
+
+// eventGroup is a set of inheritable fields that are inherited together.
+// Fields that do not belong to eventFieldGroups form groups of their own.
+type eventGroup struct {
+	// terms are lowercased terms of all fields of the group.
+	terms []string
+	// flds are indices of the fields of the group in arch.eventFlds.
+	flds []int
+}
+
+// eventGroups groups inheritable fields of the Event core.
+func (a *arch) eventGroups() []eventGroup {
+	var res []eventGroup
+	grpIdx := make(map[string]int)
+	for i, v := range a.eventFlds {
+		terms := []string{v.term}
+		for _, g := range eventFieldGroups {
+			if slices.Contains(g, v.term) {
+				terms = g
+				break
+			}
+		}
+		if j, ok := grpIdx[terms[0]]; ok {
+			res[j].flds = append(res[j].flds, i)
+			continue
+		}
+		grpIdx[terms[0]] = len(res)
+		res = append(res, eventGroup{terms: terms, flds: []int{i}})
+	}
+	return res
+}
+
+// eventFields returns inheritable fields present in the Event core.
+func (a *arch) eventFields() []eventField {
+	var res []eventField
+	for _, term := range eventInherited {
+		if v, ok := a.metaSimple.CoreData.FieldsData[term]; ok {
+			res = append(res, eventField{
+				term:     term,
+				termFull: v.TermFull,
+				index:    v.Index,
+			})
+		}
+	}
+	return res
+}
+
+// eventID returns the ID of an Event core record.
+func (a *arch) eventID(row []string) string {
+	if idx := a.meta.Core.ID.Idx; idx != -1 {
+		return a.taxon.field(row, idx)
+	}
+	if v, ok := a.metaSimple.CoreData.FieldsData["eventid"]; ok {
+		return a.taxon.field(row, v.Index)
+	}
+	return ""
+}
+
+// indexEvents reads Event core and saves parentEventID and inheritable
+// fields of every event.
+func (a *arch) indexEvents() error {
+	a.eventFlds = a.eventFields()
+	a.eventGrps = a.eventGroups()
+	parentIdx := -1
+	if v, ok := a.metaSimple.CoreData.FieldsData["parenteventid"]; ok {
+		parentIdx = v.Index
+	}
+
+	events := make(map[string]eventNode)
+	err := a.coreScan(func(_ int, row []string) bool {
+		id := a.eventID(row)
+		if id == "" {
+			return true
+		}
+		node := eventNode{
+			parentID: a.taxon.field(row, parentIdx),
+			values:   make([]string, len(a.eventFlds)),
+		}
+		for i, v := range a.eventFlds {
+			node.values[i] = a.taxon.field(row, v.index)
+		}
+		events[id] = node
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	a.events = events
+	return nil
+}
+
+// eventValues returns inheritable fields of an event. Empty groups of
+// fields are taken from the closest ancestor event where they are not
+// empty.
+func (a *arch) eventValues(id string) []string {
+	node, ok := a.events[id]
+	if !ok {
+		return nil
+	}
+	res := slices.Clone(node.values)
+	seen := map[string]struct{}{id: {}}
+	for node.parentID != "" {
+		if _, ok := seen[node.parentID]; ok {
+			slog.Warn("Cycle in parentEventID", "eventID", id)
+			break
+		}
+		seen[node.parentID] = struct{}{}
+
+		node, ok = a.events[node.parentID]
+		if !ok {
+			break
+		}
+		for _, g := range a.eventGrps {
+			if !isGroupEmpty(res, g.flds) {
+				continue
+			}
+			for _, i := range g.flds {
+				res[i] = node.values[i]
+			}
+		}
+	}
+	return res
+}
+
+// isGroupEmpty checks if values of all fields of a group are empty.
+func isGroupEmpty(vals []string, flds []int) bool {
+	for _, i := range flds {
+		if vals[i] != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// inheritEventValues fills empty groups of inheritable fields of a row
+// with values of its event. The vals are given by eventValues, idxs are
+// indices of inheritable fields in the row, fields contain all fields of
+// the row by their lowercased terms.
+func (a *arch) inheritEventValues(
+	row, vals []string,
+	idxs []int,
+	fields map[string]meta.FieldData,
+) {
+	if vals == nil {
+		return
+	}
+	for _, g := range a.eventGrps {
+		if !a.isRowGroupEmpty(row, g.terms, fields) {
+			continue
+		}
+		for _, i := range g.flds {
+			a.setField(row, idxs[i], vals[i])
+		}
+	}
+}
+
+// isRowGroupEmpty checks if all fields of a group are empty in a row,
+// including fields that are not present in the Event core.
+func (a *arch) isRowGroupEmpty(
+	row []string,
+	terms []string,
+	fields map[string]meta.FieldData,
+) bool {
+	for _, term := range terms {
+		if v, ok := fields[term]; ok && a.taxon.field(row, v.Index) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// inheritEventFields fills empty groups of inheritable fields of an Event
+// core row with values of its parent events.
+func (a *arch) inheritEventFields(row []string) {
+	idxs := make([]int, len(a.eventFlds))
+	for i, v := range a.eventFlds {
+		idxs[i] = v.index
+	}
+	vals := a.eventValues(a.eventID(row))
+	a.inheritEventValues(row, vals, idxs, a.metaSimple.CoreData.FieldsData)
+}
+
+// eventOccurrence connects rows of an Occurrence extension to their
+// events in an Event core.
+type eventOccurrence struct {
+	// coreIdx is the index of the coreid field.
+	coreIdx int
+
+	// eventID is the index of eventID field, or -1.
+	eventID int
+
+	// width is the number of fields in output rows.
+	width int
+
+	// fields are indices of inheritable fields in output rows, indexed
+	// the same way as arch.eventFlds.
+	fields []int
+
+	// terms are fields of the extension by their lowercased terms.
+	terms map[string]meta.FieldData
+
+	// occ contains indices of normalized fields of the extension.
+	occ *occurrence
+}
+
+// isEventOccurrence returns true if the extension contains occurrences of
+// an Event core.
+func (a *arch) isEventOccurrence(idx int) bool {
+	if a.events == nil {
+		return false
+	}
+	rowType := a.meta.Extensions[idx].RowType
+	return strings.ToLower(filepath.Base(rowType)) == "occurrence"
+}
+
+// newEventOccurrence prepares conversion of an Occurrence extension.
+// Inheritable fields that exist in the Event core but are missing in the
+// extension are added to the output metadata of the extension.
+func (a *arch) newEventOccurrence(idx int) *eventOccurrence {
+	ext := a.meta.Extensions[idx]
+	outExt := a.outputMeta.Extensions[idx]
+
+	fields := make(map[string]meta.FieldData)
+	for _, v := range ext.Fields {
+		term := strings.ToLower(filepath.Base(v.Term))
+		fields[term] = meta.FieldData{Index: v.Idx, Term: term, TermFull: v.Term}
+	}
+
+	maxIdx := ext.CoreID.Idx
+	if len(ext.Fields) > 0 {
+		maxIdx = max(maxIdx, slices.MaxFunc(ext.Fields, func(a, b meta.Field) int {
+			return cmp.Compare(a.Idx, b.Idx)
+		}).Idx)
+	}
+
+	res := eventOccurrence{
+		coreIdx: ext.CoreID.Idx,
+		eventID: -1,
+		width:   maxIdx + 1,
+		fields:  make([]int, len(a.eventFlds)),
+		terms:   fields,
+		occ:     newOccurrence(fields),
+	}
+	if v, ok := fields["eventid"]; ok {
+		res.eventID = v.Index
+	}
+
+	for i, v := range a.eventFlds {
+		if fd, ok := fields[v.term]; ok {
+			res.fields[i] = fd.Index
+			continue
+		}
+		idx := res.width
+		res.width++
+		res.fields[i] = idx
+		outExt.Fields = append(outExt.Fields, meta.Field{
+			Term:  v.termFull,
+			Idx:   idx,
+			Index: strconv.Itoa(idx),
+		})
+		res.occ.update(v.term, idx)
+	}
+	return &res
+}
+
+// eventOccurrenceOutput adds data of events to occurrences from chIn and
+// sends results to chOut.
+func (a *arch) eventOccurrenceOutput(
+	ctx context.Context,
+	eo *eventOccurrence,
+	chIn <-chan []string,
+	chOut chan<- []string,
+) error {
+	defer close(chOut)
+	for row := range chIn {
+		row = a.eventOccurrenceRow(eo, row)
+		select {
+		case <-ctx.Done():
+			for range chIn {
+			}
+			return ctx.Err()
+		case chOut <- row:
+		}
+	}
+	return nil
+}
+
+func (a *arch) eventOccurrenceRow(eo *eventOccurrence, row []string) []string {
+	if len(row) > eo.width {
+		row = row[:eo.width]
+	}
+	row = append(row, make([]string, eo.width-len(row))...)
+
+	eventID := a.taxon.field(row, eo.coreIdx)
+	if a.taxon.field(row, eo.eventID) == "" {
+		a.setField(row, eo.eventID, eventID)
+	}
+
+	vals := a.eventValues(eventID)
+	a.inheritEventValues(row, vals, eo.fields, eo.terms)
+	a.normalizeOccurrence(eo.occ, row)
+	return row
+}
@@ -0,0 +1,395 @@
+package dwca
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/gnames/dwca/pkg/ent/diagn"
+	"github.com/gnames/gnparser"
+	"github.com/gnames/gnuuid"
+	"golang.org/x/sync/errgroup"
+)
+
+// flatHier keeps data needed to convert a flat hierarchy (kingdom, phylum,
+// class etc. fields) into a parent-child tree.
+type flatHier struct {
+	// ids maps a classification path to the ID of a taxon. The taxon either
+	// exists in the Core, or is generated.
+	ids map[string]string
+
+	// higher contains generated higher taxa that do not exist in the Core.
+	// Parents are always placed before their children.
+	higher []higherTaxon
+
+	mx sync.Mutex
+}
+
+// higherTaxon is a taxon generated from a flat hierarchy.
+type higherTaxon struct {
+	id, parentID string
+	path         []hierElement
+}
+
+// hierElement is one element of a classification path.
+type hierElement struct {
+	rank, name string
+}
+
+// isFlatToTree returns true if the Core has only a flat hierarchy, and it
+// has to be converted to a parent-child tree.
+func (a *arch) isFlatToTree() bool {
+	return a.coreType == coreTaxon &&
+		a.dgn.HierType == diagn.HierFlat && a.flatHierarchy()
+}
+
+// indexFlatHierarchy goes through the Core and finds taxa that correspond
+// to elements of the flat hierarchy. For example, a genus that is present
+// in the Core as a separate record. Such records are used as parents
+// instead of generated ones.
+func (a *arch) indexFlatHierarchy() error {
+	a.hier = &flatHier{ids: make(map[string]string)}
+
+	ch := make(chan []string)
+	g, ctx := errgroup.WithContext(context.Background())
+	g.Go(func() error {
+		for row := range ch {
+			if a.isSynonym(row) {
+				continue
+			}
+			path := a.rowPath(row)
+			if !a.isPathTaxon(row, path) {
+				continue
+			}
+			id := a.rowID(row)
+			if id == "" {
+				continue
+			}
+			key := pathKey(path)
+			if _, ok := a.hier.ids[key]; !ok {
+				a.hier.ids[key] = id
+			}
+		}
+		return nil
+	})
+
+	_, err := a.CoreStream(ctx, ch)
+	if err != nil {
+		return err
+	}
+	return g.Wait()
+}
+
+// flatParentID returns ID of the parent taxon according to the flat
+// hierarchy of the row. Synonyms do not get parents.
+func (a *arch) flatParentID(row []string) string {
+	if a.isSynonym(row) {
+		return ""
+	}
+	path := a.rowPath(row)
+	if a.isPathTaxon(row, path) {
+		path = path[:len(path)-1]
+	}
+	if len(path) == 0 {
+		return ""
+	}
+	return a.hier.pathID(path)
+}
+
+// rowPath returns classification of a row according to its flat
+// hierarchy fields.
+func (a *arch) rowPath(row []string) []hierElement {
+	var res []hierElement
+	for _, v := range a.taxon.hierarchy {
+		name := a.taxon.field(row, v.index)
+		if name == "" {
+			continue
+		}
+		res = append(res, hierElement{rank: v.rank, name: name})
+	}
+	return res
+}
+
+// isPathTaxon returns true if the row represents the last element of its
+// own classification path, for example a genus record with its genus
+// field filled.
+func (a *arch) isPathTaxon(row []string, path []hierElement) bool {
+	if len(path) == 0 {
+		return false
+	}
+	rank := strings.ToLower(a.taxon.field(row, a.taxon.taxonRank))
+	return rank == path[len(path)-1].rank
+}
+
+// rowID returns the ID of a Core row.
+func (a *arch) rowID(row []string) string {
+	idx := a.meta.Core.ID.Idx
+	if idx == -1 {
+		idx = a.taxon.taxonID
+	}
+	return a.taxon.field(row, idx)
+}
+
+// pathID returns the ID of a taxon at the end of the path. If the taxon
+// (or any of its parents) does not exist, it is generated.
+func (h *flatHier) pathID(path []hierElement) string {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	var parentID string
+	for i := range path {
+		key := pathKey(path[:i+1])
+		id, ok := h.ids[key]
+		if !ok {
+			id = gnuuid.New(key).String()
+			h.ids[key] = id
+			h.higher = append(h.higher, higherTaxon{
+				id:       id,
+				parentID: parentID,
+				path:     path[:i+1],
+			})
+		}
+		parentID = id
+	}
+	return parentID
+}
+
+// higherTaxaRows creates Core rows for generated higher taxa.
+func (a *arch) higherTaxaRows() [][]string {
+	if a.hier == nil {
+		return nil
+	}
+
+	rankIdx := make(map[string]int)
+	for _, v := range a.taxon.hierarchy {
+		rankIdx[v.rank] = v.index
+	}
+
+	res := make([][]string, len(a.hier.higher))
+	for i, v := range a.hier.higher {
+		row := make([]string, a.outWidth)
+		last := v.path[len(v.path)-1]
+		for _, e := range v.path {
+			row[rankIdx[e.rank]] = e.name
+		}
+		a.setField(row, a.meta.Core.ID.Idx, v.id)
+		a.setField(row, a.taxon.taxonID, v.id)
+		a.setField(row, a.taxon.scientificName, last.name)
+		a.setField(row, a.taxon.taxonRank, last.rank)
+		a.setField(row, a.taxon.taxonomicStatus, "accepted")
+		a.setField(row, a.outIdx("scientificnamestring"), last.name)
+		a.setField(row, a.outIdx("parentnameusageid"), v.parentID)
+		res[i] = row
+	}
+	return res
+}
+
+// setField sets a value of a row field, if the field exists.
+func (a *arch) setField(row []string, idx int, val string) {
+	if idx < 0 || idx >= len(row) {
+		return
+	}
+	row[idx] = val
+}
+
+func pathKey(path []hierElement) string {
+	res := make([]string, len(path))
+	for i, v := range path {
+		res[i] = v.rank + ":" + v.name
+	}
+	return strings.Join(res, "|")
+}
+
+// flatRanks are ranks of flat hierarchy fields that are generated from
+// a parent-child tree.
+var flatRanks = []string{"kingdom", "phylum", "class", "order", "family", "genus"}
+
+// coreNode contains information about a Core record that is needed to
+// traverse the parent-child tree.
+type coreNode struct {
+	parentID, acceptedID, rank, name string
+}
+
+// isTreeToFlat returns true if the Core has a parent-child tree and flat
+// hierarchy fields have to be generated from it.
+func (a *arch) isTreeToFlat() bool {
+	return a.coreType == coreTaxon &&
+		a.dgn.HierType != diagn.HierUnknown && !a.flatHierarchy()
+}
+
+// indexTree creates an in-memory index of all Core records by their IDs.
+// The index allows to walk the parent-child tree.
+func (a *arch) indexTree() error {
+	type idNode struct {
+		id   string
+		node coreNode
+	}
+	nodes := make(map[string]coreNode)
+
+	chIn := make(chan []string)
+	chOut := make(chan idNode)
+	g, ctx := errgroup.WithContext(context.Background())
+	var wg sync.WaitGroup
+
+	for i := 0; i < a.cfg.JobsNum; i++ {
+		wg.Add(1)
+		g.Go(func() error {
+			defer wg.Done()
+			p := <-a.gnpPool
+			defer func() { a.gnpPool <- p }()
+
+			for row := range chIn {
+				id := a.rowID(row)
+				if id == "" {
+					continue
+				}
+				node := coreNode{
+					parentID: a.parentID(row),
+					rank:     strings.ToLower(a.taxon.field(row, a.taxon.taxonRank)),
+					name:     a.canonical(p, row),
+				}
+				if idx := a.taxon.acceptedNameUsageID; idx != -1 {
+					node.acceptedID = a.taxon.field(row, idx)
+				} else if a.isSynHierarchy() && a.isSynonym(row) {
+					node.acceptedID = node.parentID
+				}
+				chOut <- idNode{id: id, node: node}
+			}
+			return nil
+		})
+	}
+
+	go func() {
+		wg.Wait()
+		close(chOut)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		for v := range chOut {
+			nodes[v.id] = v.node
+		}
+		close(done)
+	}()
+
+	_, err := a.CoreStream(ctx, chIn)
+	if err != nil {
+		return err
+	}
+	err = g.Wait()
+	<-done
+	if err != nil {
+		return err
+	}
+
+	a.nodes = nodes
+	return nil
+}
+
+// canonical returns a canonical form of the row's scientific name.
+func (a *arch) canonical(p gnparser.GNparser, row []string) string {
+	var name string
+	if a.dgn.SciNameType == diagn.SciNameComposite {
+		name, _ = a.taxon.genCompositeName(row)
+	} else {
+		name, _ = a.taxon.genNameAu(row)
+	}
+	parsed := p.ParseName(name)
+	if parsed.Parsed && parsed.Canonical != nil {
+		return parsed.Canonical.Simple
+	}
+	return name
+}
+
+// treeClassification fills flat hierarchy fields and higherClassification
+// field using the parent-child tree. Synonyms get the classification of
+// their accepted names. Fields that already have data are not changed.
+func (a *arch) treeClassification(row []string) {
+	id := a.rowID(row)
+	node, ok := a.nodes[id]
+	if !ok {
+		return
+	}
+	a.nodeClassification(row, id, node)
+}
+
+// nodeClassification fills classification fields of a row using the
+// parent-child tree node of the row.
+func (a *arch) nodeClassification(row []string, id string, node coreNode) {
+	// lineage starts from the taxon itself and goes up to the root.
+	lineage := []coreNode{node}
+	if node.acceptedID != "" && node.acceptedID != id {
+		accID := a.acceptedID(node.acceptedID)
+		if acc, ok := a.nodes[accID]; ok {
+			lineage = []coreNode{acc}
+			id = accID
+		}
+	}
+
+	seen := map[string]struct{}{id: {}}
+	parentID := lineage[0].parentID
+	for parentID != "" {
+		if _, ok := seen[parentID]; ok {
+			break
+		}
+		seen[parentID] = struct{}{}
+		parent, ok := a.nodes[parentID]
+		if !ok {
+			break
+		}
+		lineage = append(lineage, parent)
+		parentID = parent.parentID
+	}
+
+	for _, rank := range flatRanks {
+		idx := a.taxon.rankIndex(rank)
+		if idx == -1 {
+			idx = a.outIdx(rank)
+		}
+		if idx == -1 || a.taxon.field(row, idx) != "" {
+			continue
+		}
+		for _, v := range lineage {
+			if v.rank == rank {
+				a.setField(row, idx, v.name)
+				break
+			}
+		}
+	}
+
+	idx := a.taxon.higherClassification
+	if idx == -1 {
+		idx = a.outIdx("higherclassification")
+	}
+	if idx == -1 || a.taxon.field(row, idx) != "" {
+		return
+	}
+	names := make([]string, 0, len(lineage)-1)
+	for i := len(lineage) - 1; i > 0; i-- {
+		if lineage[i].name != "" {
+			names = append(names, lineage[i].name)
+		}
+	}
+	a.setField(row, idx, strings.Join(names, "|"))
+}
+
+// indexHierarchy creates indices that are needed to normalize hierarchy
+// of the Core.
+func (a *arch) indexHierarchy() error {
+	if a.isFlatToTree() {
+		slog.Info("Building parent-child tree from flat hierarchy")
+		return a.indexFlatHierarchy()
+	}
+
+	if a.isTreeToFlat() {
+		slog.Info("Building flat hierarchy from parent-child tree")
+		return a.indexTree()
+	}
+
+	if a.isSynHierarchy() {
+		slog.Info("Resolving synonyms to accepted names")
+		return a.indexTree()
+	}
+	return nil
+}
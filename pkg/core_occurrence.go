@@ -0,0 +1,284 @@
+package dwca
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// coordPrecision is the number of decimal places kept in coordinates.
+// Six decimal places correspond to about 0.1 m at the equator.
+const coordPrecision = 6
+
+// basisOfRecordVocab maps simplified verbatim basisOfRecord values to the
+// terms of the DarwinCore controlled vocabulary.
+var basisOfRecordVocab = map[string]string{
+	"preservedspecimen":  "PreservedSpecimen",
+	"specimen":           "PreservedSpecimen",
+	"herbariumsheet":     "PreservedSpecimen",
+	"fossilspecimen":     "FossilSpecimen",
+	"fossil":             "FossilSpecimen",
+	"livingspecimen":     "LivingSpecimen",
+	"living":             "LivingSpecimen",
+	"materialsample":     "MaterialSample",
+	"sample":             "MaterialSample",
+	"materialcitation":   "MaterialCitation",
+	"literature":         "MaterialCitation",
+	"humanobservation":   "HumanObservation",
+	"observation":        "HumanObservation",
+	"machineobservation": "MachineObservation",
+	"occurrence":         "Occurrence",
+	"event":              "Event",
+	"taxon":              "Taxon",
+}
+
+var (
+	yearRe    = regexp.MustCompile(`^\d{4}$`)
+	numDateRe = regexp.MustCompile(`^(\d{1,2})[./-](\d{1,2})[./-](\d{4})$`)
+	shortEnd  = regexp.MustCompile(`^\d{1,2}$`)
+)
+
+// Layouts of dates with time, ordered from more to less precise.
+var (
+	zoneLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02T15:04Z0700",
+	}
+	timeLayouts = []string{
+		"2006-1-2T15:04:05",
+		"2006-1-2T15:04",
+		"2006-1-2 15:04:05",
+		"2006-1-2 15:04",
+	}
+	dayLayouts = []string{
+		"2006-1-2",
+		"2006/1/2",
+		"2006.1.2",
+		"20060102",
+		"2 Jan 2006",
+		"2 January 2006",
+		"2-Jan-2006",
+		"2-January-2006",
+		"Jan 2, 2006",
+		"January 2, 2006",
+		"Jan 2 2006",
+		"January 2 2006",
+	}
+	monthLayouts = []string{
+		"2006-1",
+		"2006/1",
+		"Jan 2006",
+		"January 2006",
+		"Jan-2006",
+	}
+)
+
+// normalizeOccurrence normalizes fields of an Occurrence row: eventDate,
+// decimalLatitude, decimalLongitude and basisOfRecord. Fields indices are
+// taken from o.
+func (a *arch) normalizeOccurrence(o *occurrence, row []string) {
+	if o == nil {
+		return
+	}
+
+	if o.eventDate != -1 {
+		date := a.taxon.field(row, o.eventDate)
+		if date == "" {
+			date = dateFromParts(
+				a.taxon.field(row, o.year),
+				a.taxon.field(row, o.month),
+				a.taxon.field(row, o.day),
+			)
+		} else if iso := normEventDate(date); iso != "" {
+			date = iso
+		}
+		a.setField(row, o.eventDate, date)
+	}
+
+	if o.decimalLatitude != -1 && o.decimalLongitude != -1 {
+		lat := a.taxon.field(row, o.decimalLatitude)
+		lon := a.taxon.field(row, o.decimalLongitude)
+		if latNorm, lonNorm, ok := normCoords(lat, lon); ok {
+			a.setField(row, o.decimalLatitude, latNorm)
+			a.setField(row, o.decimalLongitude, lonNorm)
+		} else {
+			o.badCoords.Add(1)
+		}
+	}
+
+	if o.basisOfRecord != -1 {
+		bor := a.taxon.field(row, o.basisOfRecord)
+		a.setField(row, o.basisOfRecord, normBasisOfRecord(bor))
+	}
+}
+
+// normEventDate converts a date or a date range to ISO 8601 format.
+// Partial dates keep their precision, for example "Jan 2020" becomes
+// "2020-01". It returns an empty string if the date cannot be parsed.
+func normEventDate(s string) string {
+	s = strings.TrimSpace(s)
+	if res := normDate(s); res != "" {
+		return res
+	}
+
+	for _, sep := range []string{"/", " - ", " to "} {
+		start, end, ok := strings.Cut(s, sep)
+		if !ok {
+			continue
+		}
+		start = normDate(strings.TrimSpace(start))
+		if start == "" {
+			continue
+		}
+		end = strings.TrimSpace(end)
+		// ISO 8601 allows to omit the leading parts of the end date,
+		// for example "2020-01-05/10".
+		if shortEnd.MatchString(end) {
+			i := strings.LastIndex(start, "-")
+			if i == -1 {
+				continue
+			}
+			end = start[:i+1] + end
+		}
+		end = normDate(end)
+		if end == "" {
+			continue
+		}
+		return start + "/" + end
+	}
+	return ""
+}
+
+// normDate converts one date to ISO 8601 format.
+func normDate(s string) string {
+	if s == "" {
+		return ""
+	}
+	if yearRe.MatchString(s) {
+		return s
+	}
+
+	for _, l := range zoneLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	for _, l := range timeLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t.Format("2006-01-02T15:04:05")
+		}
+	}
+	for _, l := range dayLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t.Format(time.DateOnly)
+		}
+	}
+	for _, l := range monthLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t.Format("2006-01")
+		}
+	}
+
+	// dd/mm/yyyy or mm/dd/yyyy, only if the order is not ambiguous.
+	m := numDateRe.FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	first, _ := strconv.Atoi(m[1])
+	second, _ := strconv.Atoi(m[2])
+	day, month := first, second
+	switch {
+	case first > 12 && second <= 12:
+	case second > 12 && first <= 12:
+		day, month = second, first
+	default:
+		return ""
+	}
+	return dateFromParts(m[3], strconv.Itoa(month), strconv.Itoa(day))
+}
+
+// dateFromParts creates ISO 8601 date from year, month and day fields.
+// It keeps as much precision as possible.
+func dateFromParts(year, month, day string) string {
+	if !yearRe.MatchString(year) {
+		return ""
+	}
+	y, _ := strconv.Atoi(year)
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 {
+		return year
+	}
+	d, err := strconv.Atoi(day)
+	// the day is absent or does not exist in the month.
+	if err != nil || d < 1 || d > daysIn(y, m) {
+		return fmt.Sprintf("%04d-%02d", y, m)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+}
+
+// daysIn returns the number of days in a month of a year.
+func daysIn(year, month int) int {
+	switch month {
+	case 2:
+		if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+			return 29
+		}
+		return 28
+	case 4, 6, 9, 11:
+		return 30
+	default:
+		return 31
+	}
+}
+
+// normCoords validates and rounds decimal coordinates. If any of the
+// coordinates is not a number or is out of range, it returns false.
+func normCoords(lat, lon string) (string, string, bool) {
+	if lat == "" && lon == "" {
+		return "", "", true
+	}
+	latF, ok := parseCoord(lat, 90)
+	if !ok {
+		return "", "", false
+	}
+	lonF, ok := parseCoord(lon, 180)
+	if !ok {
+		return "", "", false
+	}
+	return formatCoord(latF), formatCoord(lonF), true
+}
+
+func parseCoord(s string, limit float64) (float64, bool) {
+	s = strings.Replace(strings.TrimSpace(s), ",", ".", 1)
+	res, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(res) || math.Abs(res) > limit {
+		return 0, false
+	}
+	return res, true
+}
+
+func formatCoord(f float64) string {
+	pow := math.Pow10(coordPrecision)
+	f = math.Round(f*pow) / pow
+	if f == 0 {
+		// avoid "-0"
+		f = 0
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// normBasisOfRecord maps basisOfRecord to the DarwinCore controlled
+// vocabulary. Unknown values are returned unchanged.
+func normBasisOfRecord(s string) string {
+	key := strings.ToLower(s)
+	key = strings.NewReplacer(" ", "", "_", "", "-", "").Replace(key)
+	if res, ok := basisOfRecordVocab[key]; ok {
+		return res
+	}
+	return s
+}
@@ -12,7 +12,7 @@ import (
 	"sync"
 
 	"github.com/gnames/dwca/internal/ent/dcfile"
-	"github.com/gnames/dwca/internal/ent/diagn"
+	"github.com/gnames/dwca/pkg/ent/diagn"
 	"github.com/gnames/dwca/pkg/ent/meta"
 	"github.com/gnames/gnparser"
 	"golang.org/x/sync/errgroup"
@@ -32,10 +32,28 @@ func (a *arch) processCoreOutput() error {
 	// taxon is a helper object to handle DarwinCore fields that are relevant
 	// for name and hierarchy.
 	a.taxon = a.newTaxon()
+	if a.coreType == coreOccurrence || a.coreType == coreEvent {
+		a.occ = newOccurrence(a.metaSimple.CoreData.FieldsData)
+	}
 
 	// add new fields to Core metadata
 	a.updateOutputCore(maxIdx)
 
+	if !a.isNormalized() {
+		err := a.indexHierarchy()
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.coreType == coreEvent {
+		slog.Info("Resolving inherited fields of events")
+		err := a.indexEvents()
+		if err != nil {
+			return err
+		}
+	}
+
 	// context for the whole process
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -53,30 +71,38 @@ func (a *arch) processCoreOutput() error {
 		})
 	}
 
+	// add generated higher taxa and synonyms from the synonym extension,
 	// close chOut when all workers are done
-	go func() {
+	g.Go(func() error {
+		defer close(chOut)
 		wg.Wait()
-		close(chOut)
-	}()
+		for _, row := range a.higherTaxaRows() {
+			select {
+			case <-ctx.Done():
+				return &dcfile.ErrContext{Err: ctx.Err()}
+			case chOut <- row:
+			}
+		}
+		if !a.isNormalized() && a.isSynExtension() {
+			return a.synExtensionOutput(ctx, chOut)
+		}
+		return nil
+	})
 
 	// save output to a file
 	g.Go(func() error {
 		return a.saveCoreOutput(ctx, chOut)
 	})
 
+	// goroutines are finished before returning, even if reading fails.
 	_, err := a.CoreStream(ctx, chIn)
-	if err != nil {
-		return err
+	if gErr := g.Wait(); gErr != nil {
+		return gErr
 	}
-
-	if err := g.Wait(); err != nil {
-		if _, ok := err.(*dcfile.ErrContext); ok {
-			return err
-		}
-		return err
+	if a.occ != nil && err == nil {
+		a.occ.logBadCoords(a.meta.Core.Files.Location)
 	}
-
-	return nil
+	return err
 }
 
 func (a *arch) coreWorker(
@@ -106,9 +132,10 @@ func (a *arch) coreWorker(
 
 		select {
 		case <-ctx.Done():
+			for range chIn {
+			}
 			return &dcfile.ErrContext{Err: ctx.Err()}
-		default:
-			chOut <- row
+		case chOut <- row:
 		}
 	}
 	return nil
@@ -122,24 +149,56 @@ func (a *arch) isNormalized() bool {
 }
 
 func (a *arch) updateOutputCore(maxIdx int) {
+	a.outFields = make(map[string]int)
+	a.outWidth = maxIdx + 1
+	setDefaultsIndex(a.outputMeta.Core.Fields)
+	// readers convert data to UTF-8.
+	a.outputMeta.Core.Encoding = "UTF-8"
+	// data from all Core files are merged into one file.
+	a.outputMeta.Core.Files.Locations = nil
 	if a.isNormalized() {
 		return
 	}
 
 	terms := []string{
-		"scientificNameString",
+		"https://terms.speciesfilegroup.org/scientificNameString",
+	}
+	if a.isFlatToTree() {
+		terms = append(terms, "http://rs.tdwg.org/dwc/terms/parentNameUsageID")
+	}
+	if a.isSynHierarchy() ||
+		(a.isSynExtension() && a.taxon.acceptedNameUsageID == -1) {
+		terms = append(terms,
+			"http://rs.tdwg.org/dwc/terms/acceptedNameUsageID")
+	}
+	if a.isSynExtension() && a.taxon.taxonomicStatus == -1 {
+		terms = append(terms,
+			"http://rs.tdwg.org/dwc/terms/taxonomicStatus")
+	}
+	if a.isTreeToFlat() {
+		for _, rank := range flatRanks {
+			if a.taxon.rankIndex(rank) == -1 {
+				terms = append(terms, "http://rs.tdwg.org/dwc/terms/"+rank)
+			}
+		}
+		if a.taxon.higherClassification == -1 {
+			terms = append(terms,
+				"http://rs.tdwg.org/dwc/terms/higherClassification")
+		}
 	}
 
 	var idx int
-	for i, v := range terms {
+	for i, term := range terms {
 		idx = maxIdx + i + 1
-		term := "https://terms.speciesfilegroup.org/" + v
 		a.outputMeta.EMLFile = "eml.xml"
 		a.outputMeta.Core.Fields = append(
 			a.outputMeta.Core.Fields,
 			meta.Field{Term: term, Idx: idx, Index: strconv.Itoa(idx)},
 		)
+		a.outFields[strings.ToLower(filepath.Base(term))] = idx
 	}
+	a.outWidth = maxIdx + len(terms) + 1
+
 	ext := filepath.Ext(a.metaSimple.Location)
 	location := a.metaSimple.Location[:len(a.metaSimple.Location)-len(ext)] + ".txt"
 
@@ -155,6 +214,26 @@ func (a *arch) updateOutputCore(maxIdx int) {
 	a.outputMeta.Core.LinesTerminatedBy = `\n`
 }
 
+// setDefaultsIndex sets indices of fields that have only default values.
+// Readers add such fields to rows, so they become real columns in the
+// output.
+func setDefaultsIndex(fields []meta.Field) {
+	for i := range fields {
+		if fields[i].Index == "" && fields[i].Idx != -1 {
+			fields[i].Index = strconv.Itoa(fields[i].Idx)
+		}
+	}
+}
+
+// outIdx returns the index of a field added to the output Core, or -1 if
+// such field was not added.
+func (a *arch) outIdx(term string) int {
+	if idx, ok := a.outFields[term]; ok {
+		return idx
+	}
+	return -1
+}
+
 func (a *arch) saveCoreOutput(ctx context.Context, chOut <-chan []string) error {
 	file := a.outputMeta.Core.Files.Location
 
@@ -179,58 +258,106 @@ func (a *arch) processCoreRow(
 	row []string,
 	maxIdx int,
 ) ([]string, error) {
-	var res []string
 	// add empty fields if row is short, cut fields larger than maxIdx.
 	row = a.normalizeRow(row, maxIdx)
-	switch a.dgn.SciNameType {
+	// add fields for new output data.
+	row = append(row, make([]string, a.outWidth-len(row))...)
 
-	case diagn.SciNameCanonical:
-		name, author := a.taxon.genNameAu(row)
-		nameStr := strings.TrimSpace(name + " " + author)
-		nameStr = getFullName(p, nameStr, "")
-		row = append(row, nameStr)
-		res = row
+	nameStr, err := a.sciNameString(p, row)
+	if err != nil {
+		return nil, err
+	}
+	a.setField(row, a.outIdx("scientificnamestring"), nameStr)
 
-	case diagn.SciNameFull, diagn.SciNameUnknown:
-		name, auth := a.taxon.genNameAu(row)
-		nameStr := getFullName(p, name, auth)
-		row = append(row, nameStr)
-		res = row
+	if a.events != nil {
+		a.inheritEventFields(row)
+	}
 
-	case diagn.SciNameComposite:
-		slog.Error("dwca.ProcessCoreRow: SciNameComposite not implemented yet")
-		return nil, fmt.Errorf("not implemented yet")
+	if a.occ != nil {
+		a.normalizeOccurrence(a.occ, row)
+	}
+
+	if a.hier != nil {
+		a.setField(row, a.outIdx("parentnameusageid"), a.flatParentID(row))
+	}
+
+	if a.nodes != nil {
+		if a.isSynHierarchy() {
+			if a.isSynonym(row) {
+				a.resolveSynonym(row)
+			} else {
+				a.reparent(row)
+			}
+		}
+		if a.isTreeToFlat() {
+			a.treeClassification(row)
+		}
+	}
+
+	return row, nil
+}
+
+// sciNameString creates a scientific name string with authorship (if
+// available) from the name fields of the row.
+func (a *arch) sciNameString(
+	p gnparser.GNparser,
+	row []string,
+) (string, error) {
+	var nameStr string
+	switch a.dgn.SciNameType {
+
+	// Name types are detected from a proportion of rows, so every row is
+	// processed on its own: authorship is added only if the name does not
+	// have it yet, and empty names are built from their elements.
+	case diagn.SciNameCanonical, diagn.SciNameFull,
+		diagn.SciNameComposite, diagn.SciNameUnknown:
+		name, auth := a.taxon.genCompositeName(row)
+		nameStr = getFullName(p, name, auth)
 
 	default:
 		slog.Error("dwca.ProcessCoreRow: cannot process Core row")
-		return nil, fmt.Errorf("cannot process Core row")
+		return "", fmt.Errorf("cannot process Core row")
 	}
+	return nameStr, nil
+}
 
-	return res, nil
+// nullIDs are markers that some archives use instead of empty IDs.
+var nullIDs = map[string]struct{}{
+	`\N`:   {},
+	"/N":   {},
+	"NULL": {},
+	"null": {},
 }
 
+// parentID returns the ID of the parent taxon of the row. Null markers,
+// like `\N`, are returned as empty strings.
 func (a *arch) parentID(row []string) string {
 	pIdx := a.taxon.parentNameUsageID
-	if pIdx != -1 {
-		return row[pIdx]
+	if pIdx == -1 {
+		pIdx = a.taxon.higherTaxonID
+	}
+	if pIdx == -1 {
+		return ""
 	}
-	pIdx = a.taxon.higherTaxonID
-	if pIdx != -1 {
-		return row[pIdx]
+	res := a.taxon.field(row, pIdx)
+	if _, ok := nullIDs[res]; ok {
+		return ""
 	}
-	return ""
+	return res
 }
 
+// isSynonym checks if the taxonomic status of the row belongs to a
+// synonym. Statuses are compared case-insensitively.
 func (a *arch) isSynonym(row []string) bool {
 	syn := []string{"synonym", "homonym", "misapplied", "ambiguous"}
-	synPart := []string{"synonym", "miss", "un"}
+	synPart := []string{"synonym", "miss", "invalid", "unavailable"}
 	tsIdx := a.taxon.taxonomicStatus
 
 	if tsIdx == -1 {
 		return false
 	}
 
-	st := strings.TrimSpace(row[tsIdx])
+	st := strings.ToLower(a.taxon.field(row, tsIdx))
 	for i := range syn {
 		if st == syn[i] {
 			return true
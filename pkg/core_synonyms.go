@@ -0,0 +1,239 @@
+package dwca
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/gnames/dwca/pkg/ent/diagn"
+	"github.com/gnames/gnparser"
+	"github.com/gnames/gnuuid"
+	"golang.org/x/sync/errgroup"
+)
+
+// isSynHierarchy returns true if synonyms of the Core point to their
+// accepted names via parentNameUsageID or higherTaxonID fields.
+// Such synonyms are converted to use acceptedNameUsageID field.
+func (a *arch) isSynHierarchy() bool {
+	return a.coreType == coreTaxon &&
+		a.dgn.SynonymType == diagn.SynHierarchy &&
+		a.taxon.acceptedNameUsageID == -1
+}
+
+// resolveSynonym moves the accepted name ID of a synonym from the parent
+// field to acceptedNameUsageID field. The parent field gets the ID of the
+// accepted taxon's parent.
+func (a *arch) resolveSynonym(row []string) {
+	node, ok := a.nodes[a.rowID(row)]
+	if !ok || node.acceptedID == "" {
+		return
+	}
+
+	accID := a.acceptedID(node.acceptedID)
+	a.setField(row, a.acceptedIDIdx(), accID)
+
+	var parentID string
+	if acc, ok := a.nodes[accID]; ok {
+		parentID = a.acceptedParentID(acc.parentID)
+	}
+	a.setField(row, a.parentIDIdx(), parentID)
+}
+
+// reparent moves an accepted taxon that has a synonym as its parent to
+// the accepted taxon of that synonym.
+func (a *arch) reparent(row []string) {
+	parentID := a.parentID(row)
+	accID := a.acceptedParentID(parentID)
+	if accID != parentID {
+		a.setField(row, a.parentIDIdx(), accID)
+	}
+}
+
+// acceptedParentID returns the ID of the accepted taxon if the given
+// parent ID belongs to a synonym, otherwise it returns the ID unchanged.
+func (a *arch) acceptedParentID(id string) string {
+	if node, ok := a.nodes[id]; ok && node.acceptedID != "" {
+		return a.acceptedID(id)
+	}
+	return id
+}
+
+// parentIDIdx returns the index of the field with parent IDs.
+func (a *arch) parentIDIdx() int {
+	if a.taxon.parentNameUsageID != -1 {
+		return a.taxon.parentNameUsageID
+	}
+	return a.taxon.higherTaxonID
+}
+
+// acceptedID follows a chain of synonyms until it reaches an accepted
+// taxon. If the chain is broken or has a cycle, the last found ID is
+// returned.
+func (a *arch) acceptedID(id string) string {
+	seen := make(map[string]struct{})
+	for {
+		if _, ok := seen[id]; ok {
+			return id
+		}
+		seen[id] = struct{}{}
+		node, ok := a.nodes[id]
+		if !ok || node.acceptedID == "" {
+			return id
+		}
+		id = node.acceptedID
+	}
+}
+
+// synSkipFields are fields of a synonym extension that are not copied to
+// the Core, because they are generated during conversion.
+var synSkipFields = map[string]struct{}{
+	"taxonid":             {},
+	"acceptednameusageid": {},
+	"parentnameusageid":   {},
+	"highertaxonid":       {},
+	"taxonomicstatus":     {},
+}
+
+// isSynExtension returns true if synonyms are kept in a separate
+// extension. Such synonyms are converted to Core records.
+func (a *arch) isSynExtension() bool {
+	return a.coreType == coreTaxon &&
+		a.dgn.SynonymType == diagn.SynExtension && a.synExtIdx() != -1
+}
+
+// synExtIdx returns the index of the synonym extension, or -1 if such
+// extension does not exist.
+func (a *arch) synExtIdx() int {
+	for i, v := range a.meta.Extensions {
+		name := strings.ToLower(filepath.Base(v.RowType))
+		file := strings.ToLower(filepath.Base(v.Files.Location))
+		if strings.HasPrefix(name, "synonym") ||
+			strings.HasPrefix(file, "synonym") {
+			return i
+		}
+	}
+	return -1
+}
+
+// synExtensionOutput converts rows of the synonym extension to Core rows
+// and sends them to the output channel.
+func (a *arch) synExtensionOutput(
+	ctx context.Context,
+	chOut chan<- []string,
+) error {
+	idx := a.synExtIdx()
+	ext := a.meta.Extensions[idx]
+	slog.Info("Moving synonyms from extension to Core",
+		"ext", filepath.Base(ext.RowType))
+
+	// fields maps indices of the extension fields to the Core fields.
+	fields := make(map[int]int)
+	for _, v := range ext.Fields {
+		if v.Idx == ext.CoreID.Idx {
+			continue
+		}
+		term := strings.ToLower(filepath.Base(v.Term))
+		if _, ok := synSkipFields[term]; ok {
+			continue
+		}
+		if fd, ok := a.metaSimple.FieldsData[term]; ok {
+			fields[v.Idx] = fd.Index
+		}
+	}
+
+	p := <-a.gnpPool
+	defer func() { a.gnpPool <- p }()
+
+	ch := make(chan []string)
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var line int
+		for v := range ch {
+			line++
+			row, err := a.synonymRow(p, ext.CoreID.Idx, fields, v, line)
+			if err != nil {
+				for range ch {
+				}
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				for range ch {
+				}
+				return &dcfile.ErrContext{Err: ctx.Err()}
+			case chOut <- row:
+			}
+		}
+		return nil
+	})
+
+	_, err := a.ExtensionStream(ctx, idx, ch)
+	if gErr := g.Wait(); gErr != nil {
+		return gErr
+	}
+	return err
+}
+
+// synonymRow creates a Core row from a row of the synonym extension.
+// The synonym gets an ID generated from the row and its number, so
+// identical rows do not get the same ID. The Core ID of the extension row
+// becomes its acceptedNameUsageID.
+func (a *arch) synonymRow(
+	p gnparser.GNparser,
+	coreIdx int,
+	fields map[int]int,
+	extRow []string,
+	line int,
+) ([]string, error) {
+	row := make([]string, a.outWidth)
+	for from, to := range fields {
+		if from < len(extRow) {
+			row[to] = extRow[from]
+		}
+	}
+
+	var coreID string
+	if coreIdx >= 0 && coreIdx < len(extRow) {
+		coreID = strings.TrimSpace(extRow[coreIdx])
+	}
+	id := gnuuid.New(
+		"synonym|" + strconv.Itoa(line) + "|" + strings.Join(extRow, "|"),
+	).String()
+
+	a.setField(row, a.meta.Core.ID.Idx, id)
+	a.setField(row, a.taxon.taxonID, id)
+	a.setField(row, a.acceptedIDIdx(), coreID)
+	a.setField(row, a.statusIdx(), "synonym")
+
+	nameStr, err := a.sciNameString(p, row)
+	if err != nil {
+		return nil, err
+	}
+	a.setField(row, a.outIdx("scientificnamestring"), nameStr)
+
+	if a.nodes != nil && a.isTreeToFlat() {
+		a.nodeClassification(row, id, coreNode{acceptedID: coreID})
+	}
+	return row, nil
+}
+
+// acceptedIDIdx returns the index of acceptedNameUsageID field in the
+// output Core.
+func (a *arch) acceptedIDIdx() int {
+	if a.taxon.acceptedNameUsageID != -1 {
+		return a.taxon.acceptedNameUsageID
+	}
+	return a.outIdx("acceptednameusageid")
+}
+
+// statusIdx returns the index of taxonomicStatus field in the output Core.
+func (a *arch) statusIdx() int {
+	if a.taxon.taxonomicStatus != -1 {
+		return a.taxon.taxonomicStatus
+	}
+	return a.outIdx("taxonomicstatus")
+}
@@ -0,0 +1,106 @@
+package dwca
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gnames/dwca/pkg/ent/meta"
+)
+
+// coreType is the type of records in the Core, determined by its rowType.
+type coreType int
+
+const (
+	coreTaxon coreType = iota
+	coreOccurrence
+	coreEvent
+)
+
+// newCoreType determines type of the Core from its rowType. Cores with
+// unknown rowType are processed as Taxon cores.
+func newCoreType(rowType string) coreType {
+	switch strings.ToLower(filepath.Base(rowType)) {
+	case "occurrence":
+		return coreOccurrence
+	case "event":
+		return coreEvent
+	default:
+		return coreTaxon
+	}
+}
+
+func (ct coreType) String() string {
+	switch ct {
+	case coreOccurrence:
+		return "occurrence"
+	case coreEvent:
+		return "event"
+	default:
+		return "taxon"
+	}
+}
+
+// occurrence contains indices of DarwinCore fields that are normalized
+// in Occurrence cores.
+type occurrence struct {
+	eventDate,
+	year,
+	month,
+	day,
+	decimalLatitude,
+	decimalLongitude,
+	basisOfRecord int
+
+	// badCoords is the number of rows with coordinates that cannot be
+	// normalized.
+	badCoords atomic.Int64
+}
+
+// newOccurrence finds indices of occurrence fields in a Core or an
+// extension.
+func newOccurrence(fields map[string]meta.FieldData) *occurrence {
+	res := occurrence{
+		eventDate:        -1,
+		year:             -1,
+		month:            -1,
+		day:              -1,
+		decimalLatitude:  -1,
+		decimalLongitude: -1,
+		basisOfRecord:    -1,
+	}
+	for k, v := range fields {
+		res.update(k, v.Index)
+	}
+	return &res
+}
+
+// update sets the index of a field given by its lowercased term.
+func (o *occurrence) update(term string, idx int) {
+	switch term {
+	case "eventdate":
+		o.eventDate = idx
+	case "year":
+		o.year = idx
+	case "month":
+		o.month = idx
+	case "day":
+		o.day = idx
+	case "decimallatitude":
+		o.decimalLatitude = idx
+	case "decimallongitude":
+		o.decimalLongitude = idx
+	case "basisofrecord":
+		o.basisOfRecord = idx
+	}
+}
+
+// logBadCoords reports how many rows of a data file kept their coordinates
+// as is, because they are not valid decimal degrees.
+func (o *occurrence) logBadCoords(file string) {
+	if num := o.badCoords.Load(); num > 0 {
+		slog.Warn("Coordinates are not valid decimal degrees, kept as is",
+			"file", file, "rows", num)
+	}
+}
@@ -27,10 +27,12 @@ type taxon struct {
 	infraspecificEpithet,
 	scientificNameRank,
 	taxonRank,
+	nomenclaturalCode,
 	taxonomicStatus,
 	acceptedNameUsageID,
 	higherTaxonID,
 	parentNameUsageID,
+	higherClassification,
 	taxonID int
 	hierarchy []taxonHierarchy
 }
@@ -63,10 +65,12 @@ func (a *arch) newTaxon() *taxon {
 		infraspecificEpithet:     -1,
 		scientificNameRank:       -1,
 		taxonRank:                -1,
+		nomenclaturalCode:        -1,
 		taxonomicStatus:          -1,
 		acceptedNameUsageID:      -1,
 		higherTaxonID:            -1,
 		parentNameUsageID:        -1,
+		higherClassification:     -1,
 		taxonID:                  -1,
 	}
 	cr := a.metaSimple.CoreData
@@ -134,6 +138,8 @@ func (a *arch) newTaxon() *taxon {
 			res.scientificNameRank = v.Index
 		case "taxonrank":
 			res.taxonRank = v.Index
+		case "nomenclaturalcode":
+			res.nomenclaturalCode = v.Index
 		case "taxonomicstatus":
 			res.taxonomicStatus = v.Index
 		case "acceptednameusageid":
@@ -142,6 +148,8 @@ func (a *arch) newTaxon() *taxon {
 			res.higherTaxonID = v.Index
 		case "parentnameusageid":
 			res.parentNameUsageID = v.Index
+		case "higherclassification":
+			res.higherClassification = v.Index
 		case "taxonid":
 			res.taxonID = v.Index
 		}
@@ -166,3 +174,99 @@ func (n *taxon) genNameAu(row []string) (string, string) {
 	aus = strings.TrimSpace(aus)
 	return sn, aus
 }
+
+// rankIndex returns the index of a flat hierarchy field that corresponds
+// to the given rank, or -1 if such field does not exist.
+func (n *taxon) rankIndex(rank string) int {
+	for _, v := range n.hierarchy {
+		if v.rank == rank {
+			return v.index
+		}
+	}
+	return -1
+}
+
+// genCompositeName builds a scientific name out of its elements for DwCA
+// files, where names are represented by genus, specificEpithet,
+// infraspecificEpithet etc. If scientificName field exists and is not empty,
+// it is used instead. It returns the name and its authorship.
+func (n *taxon) genCompositeName(row []string) (string, string) {
+	name, aus := n.genNameAu(row)
+	if name != "" {
+		return name, aus
+	}
+	aus = n.field(row, n.scientificNameAuthorship)
+
+	gen := n.field(row, n.genericName)
+	if gen == "" {
+		gen = n.field(row, n.genus)
+	}
+	infraGen := n.field(row, n.infragenericEpithet)
+	if infraGen == "" {
+		infraGen = n.field(row, n.subgenus)
+	}
+	// subgenus field might contain a genus as well, like `Aus (Bus)`.
+	infraGen = strings.Trim(infraGen, "()")
+	if i := strings.Index(infraGen, "("); i > -1 {
+		infraGen = strings.Trim(infraGen[i:], "()")
+	}
+	sp := n.field(row, n.specificEpithet)
+	infraSp := n.field(row, n.infraspecificEpithet)
+
+	if gen == "" {
+		return "", aus
+	}
+
+	res := []string{gen}
+	if infraGen != "" {
+		res = append(res, "("+infraGen+")")
+	}
+	if sp != "" {
+		res = append(res, sp)
+	}
+	if sp != "" && infraSp != "" {
+		rank := n.field(row, n.taxonRank)
+		if rank == "" {
+			rank = n.field(row, n.scientificNameRank)
+		}
+		if marker := n.rankMarker(row, rank); marker != "" {
+			res = append(res, marker)
+		}
+		res = append(res, infraSp)
+	}
+	return strings.Join(res, " "), aus
+}
+
+// rankMarker returns an abbreviated rank for infraspecific names.
+// Zoological names do not use rank markers for subspecies.
+func (n *taxon) rankMarker(row []string, rank string) string {
+	rank = strings.ToLower(strings.TrimSpace(rank))
+	code := strings.ToLower(n.field(row, n.nomenclaturalCode))
+	isZoo := strings.HasPrefix(code, "iczn") || strings.HasPrefix(code, "zoo")
+	switch rank {
+	case "subspecies", "subsp.", "subsp", "ssp.", "ssp":
+		if isZoo {
+			return ""
+		}
+		return "subsp."
+	case "variety", "var.", "var", "varietas":
+		return "var."
+	case "subvariety", "subvar.", "subvar":
+		return "subvar."
+	case "form", "forma", "f.", "fo.":
+		return "f."
+	case "subform", "subforma", "subf.":
+		return "subf."
+	default:
+		return ""
+	}
+}
+
+// field returns trimmed value of a row at the given index, or an empty
+// string if the index is not set.
+func (n *taxon) field(row []string, idx int) string {
+	if idx == -1 || idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
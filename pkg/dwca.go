@@ -1,19 +1,24 @@
 package dwca
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
-	"io"
+	"fmt"
+	"io/fs"
 	"log/slog"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/gnames/dwca/internal/ent/dcfile"
-	"github.com/gnames/dwca/internal/ent/diagn"
 	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/diagn"
 	"github.com/gnames/dwca/pkg/ent/eml"
 	"github.com/gnames/dwca/pkg/ent/meta"
+	"github.com/gnames/dwca/pkg/ent/tree"
 	"github.com/gnames/gnlib/ent/gnvers"
 	"github.com/gnames/gnparser"
 )
@@ -27,6 +32,11 @@ type arch struct {
 	// cfg is the configuration object of the archive.
 	cfg config.Config
 
+	// extractAlias is ExtractPath of the configuration given to a factory.
+	// The archive keeps its files in its own working directory, so Load
+	// treats this path as cfg.ExtractPath.
+	extractAlias string
+
 	// dcFile is the object that handles the DwCA archive filesystem operations.
 	dcFile dcfile.DCFile
 
@@ -54,6 +64,42 @@ type arch struct {
 	// taxon contains information about DarwinCore fields that are relevant
 	// for taxon information.
 	taxon *taxon
+
+	// outFields maps lowercased terms of fields added to the output Core
+	// to their indices.
+	outFields map[string]int
+
+	// outWidth is the number of fields in the output Core rows.
+	outWidth int
+
+	// hier contains data for converting flat hierarchy to a parent-child
+	// tree. It is nil if such conversion is not needed.
+	hier *flatHier
+
+	// nodes is an index of Core records by their IDs. It is used to walk
+	// the parent-child tree. It is nil if the tree is not needed.
+	nodes map[string]coreNode
+
+	// coreType is the type of the Core records determined by its rowType.
+	coreType coreType
+
+	// occ contains information about DarwinCore fields that are
+	// normalized in Occurrence and Event cores. It is nil for other cores.
+	occ *occurrence
+
+	// events is an index of Event core records by their IDs. It is used
+	// to inherit fields from parent events. It is nil for other cores.
+	events map[string]eventNode
+
+	// eventFlds are inheritable fields of the Event core.
+	eventFlds []eventField
+
+	// eventGrps are groups of eventFlds that are inherited together.
+	eventGrps []eventGroup
+
+	// tree is the taxonomic tree of the Core. It is created on the first
+	// request.
+	tree *tree.Tree
 }
 
 // New creates a new Archive object. It takes configuration file and necessary
@@ -82,12 +128,22 @@ func (a *arch) Config() config.Config {
 	return a.cfg
 }
 
+// Diagnostics returns information about how scientific names, synonyms
+// and hierarchy are represented in the archive.
+func (a *arch) Diagnostics() *diagn.Diagnostics {
+	return a.dgn
+}
+
 // Load extracts the archive and loads data for EML and Meta.
 func (a *arch) Load(path string) error {
 	var err error
 	slog.Info("Loading data from input DwCA file")
 
+	if a.extractAlias != "" && filepath.Clean(path) == filepath.Clean(a.extractAlias) {
+		path = a.cfg.ExtractPath
+	}
 	a.root = path
+	a.tree = nil
 
 	if a.root == a.cfg.ExtractPath {
 		err = a.dcFile.Extract()
@@ -95,20 +151,22 @@ func (a *arch) Load(path string) error {
 			return err
 		}
 	}
-	path, err = a.dcFile.ArchiveDir(path)
+	fsys, dir, err := a.dcFile.FS(path)
 	if err != nil {
 		return err
 	}
 
 	slog.Info("Reading meta.xml and eml.xml files")
-	err = a.getMeta(path)
+	err = a.getMeta(fsys, dir)
 	if err != nil {
 		return err
 	}
 
+	a.setDefaultsIdx()
 	a.metaSimple = a.meta.Simplify()
+	a.coreType = newCoreType(a.meta.Core.RowType)
 
-	err = a.getEML(path)
+	err = a.getEML(fsys, dir)
 	if err != nil {
 		return err
 	}
@@ -119,6 +177,13 @@ func (a *arch) Load(path string) error {
 		return err
 	}
 
+	if a.cfg.IndexData {
+		err = a.dcFile.Index(a.root, a.meta)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -140,6 +205,8 @@ func (a *arch) EML() *eml.EML {
 // CoreSlice takes an offset and a limit and returns a slice of slices of
 // strings, each slice representing a row of the core file. If limit and
 // offset are provided, it returns the corresponding subset of the data.
+// If the index of data files exists, rows are read starting from the
+// offset, otherwise the file is scanned from the start.
 func (a *arch) CoreSlice(offset, limit int) ([][]string, error) {
 	return a.dcFile.CoreData(a.root, a.meta, offset, limit)
 }
@@ -175,42 +242,60 @@ func (a *arch) ExtensionStream(
 	return a.dcFile.ExtensionStream(ctx, index, a.root, a.meta, ch)
 }
 
-func (a *arch) getMeta(path string) error {
-	metaFile, err := os.Open(filepath.Join(path, "meta.xml"))
+func (a *arch) getMeta(fsys fs.FS, dir string) error {
+	bs, err := fs.ReadFile(fsys, path.Join(dir, "meta.xml"))
 	if err != nil {
 		return err
 	}
-	defer metaFile.Close()
 
-	a.meta, err = meta.New(metaFile)
+	a.meta, err = meta.New(bytes.NewReader(bs))
 	if err != nil {
 		return err
 	}
 
-	// rewind file back to the beginning
-	_, err = metaFile.Seek(0, io.SeekStart)
+	a.outputMeta, err = meta.New(bytes.NewReader(bs))
 	if err != nil {
 		return err
 	}
 
-	a.outputMeta, err = meta.New(metaFile)
-	if err != nil {
-		return err
+	return nil
+}
+
+// setDefaultsIdx moves fields that have only default values after the
+// last column of data files, so their values do not replace data of
+// columns that are not declared in meta.xml.
+func (a *arch) setDefaultsIdx() {
+	set := func(attr *meta.Attr, outAttr *meta.Attr, idIdx int) {
+		if !meta.HasDefaultsOnly(attr.Fields) {
+			return
+		}
+		width, err := a.dcFile.FieldsNum(a.root, attr)
+		if err != nil {
+			// the error is reported when the data are read.
+			slog.Debug("Cannot find the number of fields", "error", err)
+			return
+		}
+		meta.SetDefaultsIdx(attr.Fields, idIdx, width)
+		meta.SetDefaultsIdx(outAttr.Fields, idIdx, width)
 	}
 
-	return nil
+	set(a.meta.Core.Attr, a.outputMeta.Core.Attr, a.meta.Core.ID.Idx)
+	for i, v := range a.meta.Extensions {
+		set(v.Attr, a.outputMeta.Extensions[i].Attr, v.CoreID.Idx)
+	}
 }
 
-func (a *arch) getEML(path string) error {
+func (a *arch) getEML(fsys fs.FS, dir string) error {
 	emlFileName := "eml.xml"
 	if a.meta.EMLFile != "" {
 		emlFileName = a.meta.EMLFile
 	}
 
-	emlFile, err := os.Open(filepath.Join(path, emlFileName))
+	emlFile, err := fsys.Open(path.Join(dir, emlFileName))
 	if err != nil {
 		return err
 	}
+	defer emlFile.Close()
 
 	a.emlData, err = eml.New(emlFile)
 	if err != nil {
@@ -221,62 +306,153 @@ func (a *arch) getEML(path string) error {
 }
 
 func (a *arch) getDiagnostics() error {
-	cs, exts, err := a.coreSample()
+	exts := make(map[string]string)
+	for k, v := range a.metaSimple.ExtensionsData {
+		exts[k] = strings.ToLower(v.Location)
+	}
+
+	prs := <-a.gnpPool
+	defer func() { a.gnpPool <- prs }()
+
+	full := a.cfg.DiagnMode == "full"
+	c := diagn.NewCollector(prs, exts, full)
+	add := func(row []string) {
+		c.Add(a.rowMap(row))
+	}
+
+	var err error
+	switch a.cfg.DiagnMode {
+	case "full":
+		err = a.coreScan(func(_ int, row []string) bool {
+			add(row)
+			return true
+		})
+	case "stratified":
+		err = a.stratifiedSample(add)
+	default:
+		var dt [][]string
+		dt, err = a.CoreSlice(0, a.cfg.DiagnSampleSize)
+		for _, row := range dt {
+			add(row)
+		}
+	}
 	if err != nil {
 		return err
 	}
-	if cs == nil {
+	if c.RowsNum() == 0 {
 		return errors.New("no data in the core file")
 	}
 
-	prs := <-a.gnpPool
-	defer func() { a.gnpPool <- prs }()
-
-	res := diagn.New(prs, cs, exts)
-	a.dgn = res
+	a.dgn = c.Diagnostics()
 	return nil
 }
 
-func (a *arch) coreSample() (
-	[]map[string]string,
-	map[string]string,
-	error,
-) {
-	dt, err := a.CoreSlice(0, 1000)
+// stratifiedSample selects DiagnSampleSize rows evenly spread through the
+// whole Core file. The file is read once: every step-th row is kept, and
+// when there are too many kept rows, the step is doubled and every second
+// kept row is dropped.
+func (a *arch) stratifiedSample(add func([]string)) error {
+	size := a.cfg.DiagnSampleSize
+	step := 1
+	var kept [][]string
+	err := a.coreScan(func(i int, row []string) bool {
+		if i%step != 0 {
+			return true
+		}
+		kept = append(kept, row)
+		if len(kept) == 2*size {
+			for j := range size {
+				kept[j] = kept[2*j]
+			}
+			kept = kept[:size]
+			step *= 2
+		}
+		return true
+	})
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-	m := a.meta.Simplify()
-	coreRows := make([]map[string]string, len(dt))
-	exts := make(map[string]string)
-	for k, v := range m.ExtensionsData {
-		exts[k] = strings.ToLower(v.Location)
+
+	// kept rows are evenly spread, their number is between size and
+	// 2*size, or smaller if the file has less rows.
+	if len(kept) <= size {
+		for _, row := range kept {
+			add(row)
+		}
+		return nil
+	}
+	for j := range size {
+		add(kept[j*len(kept)/size])
 	}
-	for i, row := range dt {
-		coreRows[i] = make(map[string]string)
-		for j, val := range row {
-			if m.CoreData.FieldsIdx[j].Term == "" {
-				continue
+	return nil
+}
+
+// coreScan calls fn for every row of the Core with the row's index.
+// Scanning stops when fn returns false.
+func (a *arch) coreScan(fn func(int, []string) bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan []string)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var i int
+		for row := range ch {
+			if !fn(i, row) {
+				cancel()
+				for range ch {
+				}
+				return
 			}
-			coreRows[i][m.CoreData.FieldsIdx[j].Term] = val
+			i++
+		}
+	}()
+
+	_, err := a.CoreStream(ctx, ch)
+	<-done
+	// the context is canceled only when scanning is stopped by fn.
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// rowMap converts a Core row to a map of lowercased terms to values.
+func (a *arch) rowMap(row []string) map[string]string {
+	fields := a.metaSimple.CoreData.FieldsIdx
+	res := make(map[string]string, len(fields))
+	for j, val := range row {
+		if fields[j].Term == "" {
+			continue
 		}
+		res[fields[j].Term] = val
 	}
-	return coreRows, exts, nil
+	return res
 }
 
 func (a *arch) Normalize() error {
-	slog.Info("Processing Core")
-	err := a.processCoreOutput()
+	key := a.outputCacheKey()
+	cached, err := a.dcFile.CachedOutput(key)
 	if err != nil {
 		return err
 	}
+	if cached {
+		return nil
+	}
 
-	slog.Info("Processing Extensions")
-	err = a.processExtensionsOutput()
+	slog.Info("Processing Core")
+	err = a.processCoreOutput()
 	if err != nil {
 		return err
 	}
 
+	slog.Info("Processing Extensions")
+	extErr := a.processExtensionsOutput()
+	if extErr != nil && a.cfg.ExtErrorPolicy != "continue" {
+		return extErr
+	}
+
 	slog.Info("Saving normalized meta.xml and eml.xml files")
 	err = a.saveMetaOutput()
 	if err != nil {
@@ -288,7 +464,28 @@ func (a *arch) Normalize() error {
 		return err
 	}
 
-	return nil
+	// output with failed extensions is not cached.
+	if extErr == nil {
+		err = a.dcFile.CacheOutput(key)
+		if err != nil {
+			slog.Warn("Cannot save normalized output to cache", "error", err)
+		}
+	}
+
+	// with "continue" policy errors of failed extensions are returned
+	// after the normalized archive is created.
+	return extErr
+}
+
+// outputCacheKey creates a key for normalized output in the cache from
+// the version and settings that change the output.
+func (a *arch) outputCacheKey() string {
+	s := fmt.Sprintf("%s|%s|%d|%s|%s|%d",
+		Vers, a.cfg.OutputCSVType, a.cfg.WrongFieldsNum, a.cfg.ExtErrorPolicy,
+		a.cfg.DiagnMode, a.cfg.DiagnSampleSize,
+	)
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
 }
 
 func (a *arch) ZipNormalized(filePath string) error {
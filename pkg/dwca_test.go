@@ -1,21 +1,28 @@
 package dwca_test
 
 import (
+	"bytes"
 	"context"
+	"embed"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gnames/dwca/internal/ent/dcfile"
-	"github.com/gnames/dwca/internal/ent/diagn"
 	dwca "github.com/gnames/dwca/pkg"
 	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/diagn"
 	"github.com/gnames/gnfmt"
+	"github.com/gnames/gnsys"
 	"github.com/stretchr/testify/assert"
 )
 
+//go:embed testdata/unpacked
+var unpackedFS embed.FS
+
 func TestFactory(t *testing.T) {
 	assert := assert.New(t)
 	cfg := config.New()
@@ -267,7 +274,530 @@ func TestSynDiagnose(t *testing.T) {
 		meta := arc.Meta()
 		assert.NotNil(meta)
 
+		dgn := arc.Diagnostics()
+		assert.NotNil(dgn)
+		assert.Equal(v.snType, dgn.SynonymType, v.msg)
+
+		err = arc.Close()
+		assert.Nil(err)
+	}
+}
+
+func TestDiagnModes(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		msg     string
+		mode    string
+		size    int
+		rowsNum int
+		snType  diagn.SciNameType
+	}{
+		{"head", "head", 50, 50, diagn.SciNameFull},
+		{"stratified", "stratified", 50, 50, diagn.SciNameFull},
+		{"full", "full", 50, 491, diagn.SciNameFull},
+	}
+
+	for _, v := range tests {
+		path := filepath.Join("testdata", "diagn", "hierarchy", "gbif-small.tar.gz")
+		cfg := config.New(
+			config.OptDiagnMode(v.mode),
+			config.OptDiagnSampleSize(v.size),
+		)
+		arc, err := dwca.Factory(path, cfg)
+		assert.Nil(err)
+
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err)
+
+		dgn := arc.Diagnostics()
+		assert.Equal(v.rowsNum, dgn.RowsNum, v.msg)
+		assert.Equal(v.snType, dgn.SciNameType, v.msg)
+		assert.Greater(dgn.SciNameConfidence, 0.9, v.msg)
+		assert.Less(dgn.SciNameConfidence, 1.0, v.msg)
+		assert.Equal(diagn.HierFlat, dgn.HierType, v.msg)
+		assert.Greater(dgn.HierConfidence, 0.5, v.msg)
+		assert.Equal(1.0, dgn.FillRates["scientificname"], v.msg)
+
+		err = arc.Close()
+		assert.Nil(err)
+	}
+}
+
+func TestFieldDefaults(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "defaults.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(2, len(data))
+	assert.Equal([]string{"1", "Pinus strobus L.", "accepted", "species", "Plantae"}, data[0])
+	assert.Equal("synonym", data[1][2])
+
+	ext, err := arc.ExtensionSlice(0, 0, 0)
+	assert.Nil(err)
+	assert.Equal([]string{"1", "eastern white pine", "en"}, ext[0])
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	// default-valued fields are real columns in the output.
+	for _, v := range arc.Meta().Core.Fields {
+		assert.NotEmpty(v.Index, v.Term)
+	}
+	data, err = arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal("Plantae", data[0][4])
+	assert.Equal("accepted", data[0][2])
+
+	ext, err = arc.ExtensionSlice(0, 0, 0)
+	assert.Nil(err)
+	assert.Equal("en", ext[0][2])
+}
+
+func TestFieldDefaultsUndeclared(t *testing.T) {
+	assert := assert.New(t)
+	// the last two columns of the data are not declared in meta.xml.
+	path := filepath.Join("testdata", "defaults_undeclared.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	defer arc.Close()
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(
+		[]string{"1", "Pinus strobus L.", "Fungi", "", "Plantae"}, data[0],
+	)
+
+	recs, err := arc.CoreRecordSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal("Plantae", recs[0].Get("kingdom"))
+}
+
+func TestEncoding(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "encoding.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(3, len(data))
+	assert.Equal("Abies alba Müll.", data[1][1])
+	assert.Equal("Artemisia vulgaris Linné", data[2][1])
+
+	// BOM is removed
+	ext, err := arc.ExtensionSlice(0, 0, 0)
+	assert.Nil(err)
+	assert.Equal([]string{"2", "Weiß-Tanne"}, ext[0])
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+	assert.Equal("UTF-8", arc.Meta().Core.Encoding)
+	assert.Equal("UTF-8", arc.Meta().Extensions[0].Encoding)
+
+	data, err = arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	names := make(map[string]string)
+	for _, v := range data {
+		names[v[0]] = v[1]
+	}
+	assert.Equal("Artemisia vulgaris Linné", names["3"])
+}
+
+func TestMultiLocation(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "multi_location.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	assert.Equal(
+		[]string{"taxa1.txt", "taxa2.txt"},
+		arc.Meta().Core.Files.Paths(),
+	)
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(4, len(data))
+	assert.Equal("Acer rubrum L.", data[3][1])
+
+	data, err = arc.CoreSlice(1, 2)
+	assert.Nil(err)
+	assert.Equal(2, len(data))
+	assert.Equal("2", data[0][0])
+	assert.Equal("3", data[1][0])
+
+	ch := make(chan []string)
+	var ids []string
+	done := make(chan struct{})
+	go func() {
+		for v := range ch {
+			ids = append(ids, v[0])
+		}
+		close(done)
+	}()
+	count, err := arc.CoreStream(context.Background(), ch)
+	<-done
+	assert.Nil(err)
+	assert.Equal(4, count)
+	assert.Equal([]string{"1", "2", "3", "4"}, ids)
+
+	ext, err := arc.ExtensionSlice(0, 0, 0)
+	assert.Nil(err)
+	assert.Equal(2, len(ext))
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	// all files are merged into one.
+	assert.Equal([]string{"taxa1.txt"}, arc.Meta().Core.Files.Paths())
+	assert.Equal(1, len(arc.Meta().Extensions[0].Files.Paths()))
+	data, err = arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(4, len(data))
+	ext, err = arc.ExtensionSlice(0, 0, 0)
+	assert.Nil(err)
+	assert.Equal(2, len(ext))
+}
+
+func TestDialect(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "dialect.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	// lines terminated by \r, single quotes, two header lines.
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(3, len(data))
+	assert.Equal([]string{"1", "Pinus strobus L.", "white; soft"}, data[0])
+	assert.Equal("it's a synonym", data[1][2])
+	assert.Equal("rare; local", data[2][2])
+
+	// lines terminated by \r\n, escaped delimiter.
+	ext, err := arc.ExtensionSlice(0, 0, 0)
+	assert.Nil(err)
+	assert.Equal(2, len(ext))
+	assert.Equal([]string{"1", "eastern white pine"}, ext[0])
+	assert.Equal([]string{"3", "silver fir, European"}, ext[1])
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+	data, err = arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(3, len(data))
+}
+
+func TestStreamArchive(t *testing.T) {
+	assert := assert.New(t)
+	tests := []string{
+		"data.tar", "data.tar.gz", "data.tar.bz2", "data.tar.xz",
+	}
+	for _, v := range tests {
+		path := filepath.Join("testdata", v)
+		cfg := config.New(
+			config.OptWrongFieldsNum(gnfmt.ProcessBadRow),
+			config.OptStreamArchive(true),
+		)
+		arc, err := dwca.Factory(path, cfg)
+		assert.Nil(err, v)
+
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err, v)
+		assert.Contains(arc.EML().Dataset.Title, "Leptogastrinae", v)
+
+		// nothing is extracted.
+		assert.Equal(gnsys.DirEmpty, gnsys.GetDirState(cfg.ExtractPath), v)
+
+		data, err := arc.CoreSlice(1, 10)
+		assert.Nil(err, v)
+		assert.Equal(10, len(data), v)
+		assert.Equal("leptogastrinae:tid:42", data[0][0], v)
+
+		ch := make(chan []string)
+		go func() {
+			for range ch {
+			}
+		}()
+		count, err := arc.CoreStream(context.Background(), ch)
+		assert.Nil(err, v)
+		assert.Equal(587, count, v)
+
+		ext, err := arc.ExtensionSlice(0, 0, 0)
+		assert.Nil(err, v)
+		assert.Equal(1, len(ext), v)
+
+		err = arc.Normalize()
+		assert.Nil(err, v)
+
+		out, err := dwca.FactoryOutput(cfg)
+		assert.Nil(err, v)
+		err = out.Load(cfg.OutputPath)
+		assert.Nil(err, v)
+		data, err = out.CoreSlice(0, 0)
+		assert.Nil(err, v)
+		assert.Equal(587, len(data), v)
+
+		err = arc.Close()
+		assert.Nil(err, v)
+	}
+
+	// ZIP files are read without extraction as well.
+	cfg := config.New(config.OptStreamArchive(true))
+	arc, err := dwca.Factory(filepath.Join("testdata", "vascan.zip"), cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	assert.Equal(gnsys.DirEmpty, gnsys.GetDirState(cfg.ExtractPath))
+	data, err := arc.CoreSlice(0, 2)
+	assert.Nil(err)
+	assert.Equal([]string{"73", "26"}, []string{data[0][0], data[1][0]})
+	ext, err := arc.ExtensionSlice(0, 0, 1)
+	assert.Nil(err)
+	assert.Equal(1, len(ext))
+	err = arc.Close()
+	assert.Nil(err)
+}
+
+func TestFactoryVariants(t *testing.T) {
+	assert := assert.New(t)
+	zipData, err := os.ReadFile(filepath.Join("testdata", "grin", "data.zip"))
+	assert.Nil(err)
+
+	tests := []struct {
+		msg     string
+		factory func(config.Config) (dwca.Archive, error)
+	}{
+		{"dir", func(cfg config.Config) (dwca.Archive, error) {
+			return dwca.Factory(filepath.Join("testdata", "unpacked"), cfg)
+		}},
+		{"fs", func(cfg config.Config) (dwca.Archive, error) {
+			return dwca.FactoryFS(unpackedFS, cfg)
+		}},
+		{"reader", func(cfg config.Config) (dwca.Archive, error) {
+			r := bytes.NewReader(zipData)
+			return dwca.FactoryReaderAt(r, int64(len(zipData)), cfg)
+		}},
+	}
+
+	for _, v := range tests {
+		cfg := config.New()
+		arc, err := v.factory(cfg)
+		assert.Nil(err, v.msg)
+
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err, v.msg)
+		assert.Equal("GRIN Taxonomy", arc.EML().Dataset.Title, v.msg)
+
+		// nothing is extracted.
+		assert.Equal(gnsys.DirEmpty, gnsys.GetDirState(cfg.ExtractPath), v.msg)
+
+		data, err := arc.CoreSlice(0, 0)
+		assert.Nil(err, v.msg)
+		assert.Equal(12, len(data), v.msg)
+		assert.Equal("f888", data[0][0], v.msg)
+
+		ext, err := arc.ExtensionSlice(0, 0, 0)
+		assert.Nil(err, v.msg)
+		assert.Greater(len(ext), 0, v.msg)
+
+		err = arc.Normalize()
+		assert.Nil(err, v.msg)
+
+		err = arc.Close()
+		assert.Nil(err, v.msg)
+	}
+
+	_, err = dwca.FactoryReaderAt(bytes.NewReader([]byte("fake")), 4, config.New())
+	assert.NotNil(err)
+}
+
+func TestConcurrentArchives(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.New(
+		config.OptWrongFieldsNum(gnfmt.ProcessBadRow),
+		config.OptIsolateWorkDir(true),
+	)
+	files := []string{"data.tar.gz", "myriatrix.tar.gz"}
+
+	arcs := make([]dwca.Archive, len(files))
+	for i, v := range files {
+		arc, err := dwca.Factory(filepath.Join("testdata", v), cfg)
+		assert.Nil(err, v)
+		arcs[i] = arc
+	}
+	assert.NotEqual(arcs[0].Config().WorkPath, arcs[1].Config().WorkPath)
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(arcs))
+	for i, arc := range arcs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = arc.Load(cfg.ExtractPath)
+			if errs[i] == nil {
+				errs[i] = arc.Normalize()
+			}
+		}()
+	}
+	wg.Wait()
+	for i := range errs {
+		assert.Nil(errs[i], files[i])
+	}
+
+	counts := make([]int, len(arcs))
+	for i, arc := range arcs {
+		out, err := dwca.FactoryOutput(arc.Config())
+		assert.Nil(err)
+		err = out.Load(arc.Config().OutputPath)
+		assert.Nil(err)
+		data, err := out.CoreSlice(0, 0)
+		assert.Nil(err)
+		counts[i] = len(data)
+	}
+	assert.Equal(587, counts[0])
+	assert.NotEqual(counts[0], counts[1])
+
+	// closing one archive does not remove files of another one.
+	err := arcs[0].Close()
+	assert.Nil(err)
+	assert.NoDirExists(arcs[0].Config().WorkPath)
+	data, err := arcs[1].CoreSlice(0, 1)
+	assert.Nil(err)
+	assert.Equal(1, len(data))
+	err = arcs[1].Close()
+	assert.Nil(err)
+	assert.NoDirExists(arcs[1].Config().WorkPath)
+}
+
+func TestCache(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "data.tar.gz")
+	cfg := config.New(
+		config.OptRootPath(t.TempDir()),
+		config.OptWrongFieldsNum(gnfmt.ProcessBadRow),
+		config.OptUseCache(true),
+	)
+
+	var outputs [][][]string
+	for range 2 {
+		arc, err := dwca.Factory(path, cfg)
+		assert.Nil(err)
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err)
+		// extracted files are kept in the cache.
+		assert.Equal(gnsys.DirEmpty, gnsys.GetDirState(cfg.ExtractPath))
+		data, err := arc.CoreSlice(1, 1)
+		assert.Nil(err)
+		assert.Equal("leptogastrinae:tid:42", data[0][0])
+
+		err = arc.Normalize()
+		assert.Nil(err)
+		out, err := dwca.FactoryOutput(cfg)
+		assert.Nil(err)
+		err = out.Load(cfg.OutputPath)
+		assert.Nil(err)
+		data, err = out.CoreSlice(0, 0)
+		assert.Nil(err)
+		outputs = append(outputs, data)
+
 		err = arc.Close()
 		assert.Nil(err)
 	}
+	assert.Equal(587, len(outputs[0]))
+	assert.Equal(outputs[0], outputs[1])
+
+	es, err := dwca.CacheList(cfg)
+	assert.Nil(err)
+	assert.Equal(1, len(es))
+	assert.Equal(1, es[0].OutputsNum)
+	assert.Greater(es[0].Size, int64(0))
+
+	// a different normalization setting creates another output.
+	cfgTSV := config.New(
+		config.OptRootPath(cfg.RootPath),
+		config.OptWrongFieldsNum(gnfmt.ProcessBadRow),
+		config.OptUseCache(true),
+		config.OptOutputCSVType("tsv"),
+	)
+	arc, err := dwca.Factory(path, cfgTSV)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	err = arc.Normalize()
+	assert.Nil(err)
+	err = arc.Close()
+	assert.Nil(err)
+	es, err = dwca.CacheList(cfg)
+	assert.Nil(err)
+	assert.Equal(2, es[0].OutputsNum)
+
+	cfgPrune := config.New(
+		config.OptRootPath(cfg.RootPath),
+		config.OptCacheMaxSize(es[0].Size+1),
+	)
+	removed, err := dwca.CachePrune(cfgPrune)
+	assert.Nil(err)
+	assert.Equal(0, len(removed))
+
+	cfgPrune = config.New(
+		config.OptRootPath(cfg.RootPath),
+		config.OptCacheMaxSize(1),
+	)
+	// archives that read from the cache keep their entries.
+	arc, err = dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	removed, err = dwca.CachePrune(cfgPrune)
+	assert.Nil(err)
+	assert.Equal(0, len(removed))
+	data, err := arc.CoreSlice(1, 1)
+	assert.Nil(err)
+	assert.Equal("leptogastrinae:tid:42", data[0][0])
+	err = arc.Close()
+	assert.Nil(err)
+
+	removed, err = dwca.CachePrune(cfgPrune)
+	assert.Nil(err)
+	assert.Equal(1, len(removed))
+	es, err = dwca.CacheList(cfg)
+	assert.Nil(err)
+	assert.Equal(0, len(es))
 }
@@ -0,0 +1,24 @@
+// package cache contains information about archives kept in the cache.
+package cache
+
+import "time"
+
+// Entry contains information about one archive in the cache.
+type Entry struct {
+	// Checksum is the SHA-256 checksum of the archive file.
+	Checksum string `json:"checksum"`
+
+	// Path is the directory of the entry in the cache.
+	Path string `json:"path"`
+
+	// Size is the size of all cached files of the archive in bytes.
+	Size int64 `json:"size"`
+
+	// LastUsed is the time when the entry was created or used last time.
+	LastUsed time.Time `json:"lastUsed"`
+
+	// OutputsNum is the number of cached normalized outputs of the archive.
+	// Normalized output depends on settings, so there might be several
+	// outputs for one archive.
+	OutputsNum int `json:"outputsNum"`
+}
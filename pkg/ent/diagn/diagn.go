@@ -0,0 +1,315 @@
+// package diagn detects how ambiguous fields are used in a DwCA file.
+package diagn
+
+import (
+	"strings"
+
+	"github.com/gnames/gnparser"
+)
+
+// nameTypeMinShare is the minimal share of examined names that need to
+// have the same type for the diagnosis. It allows a few atypical rows, for
+// example names of high ranks without authorship in archives that
+// otherwise give authorship with names.
+const nameTypeMinShare = 0.9
+
+// Diagnostics contains information about how scientific names, synonyms
+// and hierarchy are represented in a DwCA file.
+type Diagnostics struct {
+	// SciNameType shows how scientific names are given.
+	SciNameType `json:"sciNameType"`
+
+	// SynonymType shows how synonyms are connected to accepted names.
+	SynonymType `json:"synonymType"`
+
+	// HierType shows how the classification hierarchy is given.
+	HierType `json:"hierType"`
+
+	// SciNameConfidence is the share of examined rows, from 0 to 1, that
+	// support SciNameType diagnosis.
+	SciNameConfidence float64 `json:"sciNameConfidence"`
+
+	// SynonymConfidence is the share of examined rows, from 0 to 1, that
+	// support SynonymType diagnosis.
+	SynonymConfidence float64 `json:"synonymConfidence"`
+
+	// HierConfidence is the share of examined rows, from 0 to 1, that
+	// support HierType diagnosis.
+	HierConfidence float64 `json:"hierConfidence"`
+
+	// RowsNum is the number of Core rows used for diagnostics.
+	RowsNum int `json:"rowsNum"`
+
+	// FillRates maps lowercased terms of Core fields to the share of
+	// examined rows where the fields are not empty.
+	FillRates map[string]float64 `json:"fillRates,omitempty"`
+}
+
+// New creates Diagnostics from a sample of Core rows and extensions data.
+// Each Core row is a map of lowercased terms to values, extensions are
+// given as a map of their lowercased names to their lowercased file
+// locations.
+func New(
+	p gnparser.GNparser,
+	d []map[string]string,
+	exts map[string]string,
+) *Diagnostics {
+	c := NewCollector(p, exts, false)
+	for _, v := range d {
+		c.Add(v)
+	}
+	return c.Diagnostics()
+}
+
+// Collector accumulates data about Core rows one by one, so diagnostics
+// can be created without keeping all rows in memory.
+type Collector struct {
+	p    gnparser.GNparser
+	exts map[string]string
+
+	// full is true if all rows are used to count name types. Otherwise
+	// counting stops after one of the name types is found more than
+	// 100 times.
+	full bool
+
+	rowsNum int
+	fields  map[string]struct{}
+	filled  map[string]int
+
+	// first is the first row, it is used to detect fields of the Core.
+	first map[string]string
+
+	namesDone                           bool
+	canonicalNum, fullNum, compositeNum int
+	synNum, synHierNum                  int
+}
+
+// NewCollector creates a new Collector. If full is true, all added rows
+// are used to detect name types.
+func NewCollector(
+	p gnparser.GNparser,
+	exts map[string]string,
+	full bool,
+) *Collector {
+	return &Collector{
+		p:      p,
+		exts:   exts,
+		full:   full,
+		fields: make(map[string]struct{}),
+		filled: make(map[string]int),
+	}
+}
+
+// RowsNum returns the number of added rows.
+func (c *Collector) RowsNum() int {
+	return c.rowsNum
+}
+
+// Add registers a Core row, which is a map of lowercased terms to values.
+func (c *Collector) Add(row map[string]string) {
+	if c.first == nil {
+		c.first = row
+	}
+	c.rowsNum++
+	for k, v := range row {
+		c.fields[k] = struct{}{}
+		if strings.TrimSpace(v) != "" {
+			c.filled[k]++
+		}
+	}
+
+	if isSynonym(row) {
+		c.synNum++
+		if checkHierarchy(row) {
+			c.synHierNum++
+		}
+	}
+
+	if !c.namesDone {
+		c.addName(row)
+	}
+}
+
+// Diagnostics creates Diagnostics from the added rows.
+func (c *Collector) Diagnostics() *Diagnostics {
+	res := Diagnostics{RowsNum: c.rowsNum}
+	res.SciNameType, res.SciNameConfidence = c.sciNameType()
+	res.SynonymType, res.SynonymConfidence = c.synonymType()
+	res.HierType, res.HierConfidence = c.hierType()
+
+	if c.rowsNum > 0 {
+		res.FillRates = make(map[string]float64)
+		for k := range c.fields {
+			res.FillRates[k] = c.fillRate(k)
+		}
+	}
+	return &res
+}
+
+func (c *Collector) fillRate(field string) float64 {
+	if c.rowsNum == 0 {
+		return 0
+	}
+	return float64(c.filled[field]) / float64(c.rowsNum)
+}
+
+func (c *Collector) hasField(field string) bool {
+	_, ok := c.fields[field]
+	return ok
+}
+
+func (c *Collector) hierType() (HierType, float64) {
+	if c.rowsNum == 0 {
+		return HierUnknown, 0
+	}
+
+	var ranks []string
+	for _, k := range []string{
+		"kingdom", "phylum", "class", "order", "family", "genus", "species",
+	} {
+		if c.hasField(k) {
+			ranks = append(ranks, k)
+		}
+	}
+
+	if len(ranks) > 5 {
+		var sum float64
+		for _, k := range ranks {
+			sum += c.fillRate(k)
+		}
+		return HierFlat, sum / float64(len(ranks))
+	}
+
+	if c.hasField("parentnameusageid") || c.hasField("highertaxonid") {
+		conf := max(c.fillRate("parentnameusageid"), c.fillRate("highertaxonid"))
+		return HierTree, conf
+	}
+
+	return HierUnknown, 1
+}
+
+func (c *Collector) synonymType() (SynonymType, float64) {
+	if c.rowsNum == 0 {
+		return SynUnknown, 0
+	}
+
+	for k, v := range c.exts {
+		if strings.HasPrefix(k, "synonym") || strings.HasPrefix(v, "synonym") {
+			return SynExtension, 1
+		}
+	}
+
+	if c.hasField("acceptednameusageid") {
+		if c.filled["acceptednameusageid"] > 0 {
+			return SynAcceptedID, 1
+		}
+		return SynAcceptedID, 0.5
+	}
+
+	if c.synHierNum > 0 {
+		return SynHierarchy, float64(c.synHierNum) / float64(c.synNum)
+	}
+
+	// no synonyms were found, or synonyms are not connected to accepted
+	// names.
+	if c.synNum == 0 {
+		return SynUnknown, 1
+	}
+	return SynUnknown, 0.5
+}
+
+// isSynonym checks if the taxonomic status of the row belongs to a
+// synonym. Statuses are compared case-insensitively.
+func isSynonym(v map[string]string) bool {
+	st := strings.ToLower(v["taxonomicstatus"])
+	for _, k := range []string{"synonym", "miss", "invalid", "unavailable"} {
+		if strings.Contains(st, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkHierarchy(v map[string]string) bool {
+	if !isSynonym(v) {
+		return false
+	}
+
+	if v["parentnameusageid"] != "" || v["highertaxonid"] != "" {
+		return true
+	}
+
+	return false
+}
+
+// addName counts a type of scientific name of the row.
+func (c *Collector) addName(v map[string]string) {
+	done := func(num int) {
+		if num > 100 && !c.full {
+			c.namesDone = true
+		}
+	}
+
+	if v["scientificname"] == "" && v["specificepithet"] != "" {
+		c.compositeNum++
+		done(c.compositeNum)
+		return
+	}
+	parsed := c.p.ParseName(v["scientificname"])
+	if !parsed.Parsed {
+		return
+	}
+
+	authField := strings.TrimSpace(v["scientificnameauthorship"])
+	if parsed.Authorship == nil && authField != "" {
+		c.canonicalNum++
+		done(c.canonicalNum)
+		return
+	}
+
+	if parsed.Authorship != nil {
+		c.fullNum++
+		done(c.fullNum)
+	}
+}
+
+func (c *Collector) sciNameType() (SciNameType, float64) {
+	if c.rowsNum == 0 {
+		return SciNameUnknown, 0
+	}
+
+	if _, ok := c.first["scientificname"]; ok {
+		if _, ok := c.first["scientificnameauthorship"]; !ok {
+			return SciNameFull, 1
+		}
+	}
+
+	total := c.canonicalNum + c.fullNum + c.compositeNum
+	if total == 0 {
+		return SciNameUnknown, 0
+	}
+
+	// the most common type wins, ties are resolved in the order of the
+	// list.
+	types := []struct {
+		tp  SciNameType
+		num int
+	}{
+		{SciNameFull, c.fullNum},
+		{SciNameCanonical, c.canonicalNum},
+		{SciNameComposite, c.compositeNum},
+	}
+	best := types[0]
+	for _, v := range types[1:] {
+		if v.num > best.num {
+			best = v
+		}
+	}
+
+	share := float64(best.num) / float64(total)
+	if share >= nameTypeMinShare {
+		return best.tp, share
+	}
+	// confidence of the unknown type is low, if one of the types dominates.
+	return SciNameUnknown, 1 - share
+}
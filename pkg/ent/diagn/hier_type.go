@@ -0,0 +1,27 @@
+package diagn
+
+// HierType describes how the classification hierarchy is represented.
+type HierType int
+
+const (
+	HierUnknown HierType = iota
+	HierTree
+	HierFlat
+)
+
+func (h HierType) String() string {
+	switch h {
+	case HierTree:
+		return "tree"
+	case HierFlat:
+		return "flat"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler interface, so the type is
+// represented as a string in JSON.
+func (h HierType) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
@@ -1,5 +1,6 @@
 package diagn
 
+// SciNameType describes how scientific names are represented.
 type SciNameType int
 
 const (
@@ -21,3 +22,9 @@ func (s SciNameType) String() string {
 		return "unknown"
 	}
 }
+
+// MarshalText implements encoding.TextMarshaler interface, so the type is
+// represented as a string in JSON.
+func (s SciNameType) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
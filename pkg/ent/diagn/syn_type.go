@@ -1,5 +1,6 @@
 package diagn
 
+// SynonymType describes how synonyms are connected to accepted names.
 type SynonymType int
 
 const (
@@ -21,3 +22,9 @@ func (st SynonymType) String() string {
 		return "unknown"
 	}
 }
+
+// MarshalText implements encoding.TextMarshaler interface, so the type is
+// represented as a string in JSON.
+func (st SynonymType) MarshalText() ([]byte, error) {
+	return []byte(st.String()), nil
+}
@@ -21,9 +21,13 @@ type CoreData struct {
 	// TermFull is the URI of the main Core Data (Topic).
 	TermFull string
 
-	// Location is the location of the Core file.
+	// Location is the location of the Core file. If there are several
+	// files, it is the location of the first one.
 	Location string
 
+	// Locations are locations of all Core files.
+	Locations []string
+
 	// FieldsData is a map of field Terms to their FieldData.
 	FieldsData map[string]FieldData
 
@@ -37,9 +41,13 @@ type ExtensionData struct {
 	// It allows to create a star schema of the DwCA archive.
 	CoreIndex int
 
-	// Location is the location of the Extension file.
+	// Location is the location of the Extension file. If there are
+	// several files, it is the location of the first one.
 	Location string
 
+	// Locations are locations of all Extension files.
+	Locations []string
+
 	// FieldsData is a map of field Terms to their FieldData.
 	FieldsData map[string]FieldData
 
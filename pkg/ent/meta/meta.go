@@ -39,8 +39,50 @@ type Extension struct {
 
 // Files holds the location of files.
 type Files struct {
-	// Location provides path to a file.
-	Location string `xml:"location"`
+	// Location provides path to a file. If there are several files, it is
+	// the path to the first one.
+	Location string
+
+	// Locations provides paths to all files, if there are more than one
+	// of them. Data from these files are concatenated in the given order.
+	Locations []string
+}
+
+// Paths returns paths to all files.
+func (f Files) Paths() []string {
+	if len(f.Locations) > 0 {
+		return f.Locations
+	}
+	if f.Location == "" {
+		return nil
+	}
+	return []string{f.Location}
+}
+
+type xmlFiles struct {
+	Locations []string `xml:"location"`
+}
+
+// UnmarshalXML decodes one or more location elements.
+func (f *Files) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var res xmlFiles
+	err := d.DecodeElement(&res, &start)
+	if err != nil {
+		return err
+	}
+	*f = Files{}
+	if len(res.Locations) > 0 {
+		f.Location = res.Locations[0]
+	}
+	if len(res.Locations) > 1 {
+		f.Locations = res.Locations
+	}
+	return nil
+}
+
+// MarshalXML encodes paths of files as location elements.
+func (f Files) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(xmlFiles{Locations: f.Paths()}, start)
 }
 
 // ID holds the fields for the Core ID.
@@ -66,4 +108,13 @@ type Field struct {
 
 	// Term is the URI of the term.
 	Term string `xml:"term,attr"`
+
+	// Default is the value of the field for rows where it is empty. If the
+	// field has no index, the value is the same for all rows, and readers
+	// add it as a new column after the last column of the file.
+	Default string `xml:"default,attr,omitempty"`
+
+	// Vocabulary is the URI of a controlled vocabulary for the values of
+	// the field.
+	Vocabulary string `xml:"vocabulary,attr,omitempty"`
 }
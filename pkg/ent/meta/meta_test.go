@@ -50,6 +50,76 @@ func TestMetaCoL(t *testing.T) {
 	assert.Equal("http://rs.gbif.org/terms/1.0/isExtinct", m.Extensions[1].Fields[1].Term)
 }
 
+func TestMetaDefaults(t *testing.T) {
+	assert := assert.New(t)
+	xml := `<archive xmlns="http://rs.tdwg.org/dwc/text/" metadata="eml.xml">
+  <core rowType="http://rs.tdwg.org/dwc/terms/Taxon">
+    <files><location>taxa.txt</location></files>
+    <id index="0"/>
+    <field index="1" term="http://rs.tdwg.org/dwc/terms/scientificName"/>
+    <field term="http://rs.tdwg.org/dwc/terms/kingdom" default="Plantae"/>
+    <field index="2" term="http://rs.tdwg.org/dwc/terms/taxonRank"
+      vocabulary="http://rs.gbif.org/vocabulary/gbif/rank"/>
+    <field term="http://rs.tdwg.org/dwc/terms/nomenclaturalCode"/>
+  </core>
+</archive>`
+	m, err := meta.New(bytes.NewReader([]byte(xml)))
+	assert.Nil(err)
+	fs := m.Core.Fields
+	assert.Equal("Plantae", fs[1].Default)
+	assert.Equal("", fs[1].Index)
+	assert.Equal(3, fs[1].Idx)
+	assert.Equal("http://rs.gbif.org/vocabulary/gbif/rank", fs[2].Vocabulary)
+	assert.Equal(-1, fs[3].Idx)
+
+	fd := m.Simplify().FieldsData
+	assert.Equal(3, fd["kingdom"].Index)
+
+	bs, err := m.Bytes()
+	assert.Nil(err)
+	assert.Contains(string(bs), `default="Plantae"`)
+	assert.NotContains(string(bs), `vocabulary=""`)
+
+	// data files have 5 columns, 2 of them are not declared.
+	assert.True(meta.HasDefaultsOnly(fs))
+	meta.SetDefaultsIdx(fs, m.Core.ID.Idx, 5)
+	assert.Equal(5, fs[1].Idx)
+	assert.Equal(2, fs[2].Idx)
+	assert.Equal(-1, fs[3].Idx)
+}
+
+func TestMetaLocations(t *testing.T) {
+	assert := assert.New(t)
+	xml := `<archive xmlns="http://rs.tdwg.org/dwc/text/" metadata="eml.xml">
+  <core rowType="http://rs.tdwg.org/dwc/terms/Taxon">
+    <files>
+      <location>taxa1.txt</location>
+      <location>taxa2.txt</location>
+    </files>
+    <id index="0"/>
+  </core>
+  <extension rowType="http://rs.gbif.org/terms/1.0/VernacularName">
+    <files><location>vernacular.txt</location></files>
+    <coreid index="0"/>
+  </extension>
+</archive>`
+	m, err := meta.New(bytes.NewReader([]byte(xml)))
+	assert.Nil(err)
+	assert.Equal("taxa1.txt", m.Core.Files.Location)
+	assert.Equal([]string{"taxa1.txt", "taxa2.txt"}, m.Core.Files.Paths())
+	assert.Equal([]string{"vernacular.txt"}, m.Extensions[0].Files.Paths())
+	assert.Equal(
+		[]string{"taxa1.txt", "taxa2.txt"},
+		m.Simplify().CoreData.Locations,
+	)
+
+	bs, err := m.Bytes()
+	assert.Nil(err)
+	m, err = meta.New(bytes.NewReader(bs))
+	assert.Nil(err)
+	assert.Equal([]string{"taxa1.txt", "taxa2.txt"}, m.Core.Files.Paths())
+}
+
 type badReader struct{}
 
 func (b badReader) Read(p []byte) (n int, err error) {
@@ -34,7 +34,37 @@ func New(r io.Reader) (*Meta, error) {
 		}
 	}
 
-	fs := res.Core.Fields
+	err = setFieldsIdx(res.Core.Fields, res.Core.ID.Idx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range res.Extensions {
+		ext := res.Extensions[i]
+		if ext.CoreID.Index == "" {
+			ext.CoreID.Idx = -1
+		} else {
+			ext.CoreID.Idx, err = strconv.Atoi(ext.CoreID.Index)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = setFieldsIdx(ext.Fields, ext.CoreID.Idx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &res, nil
+}
+
+// setFieldsIdx converts indices of fields to int. Fields without an index
+// get -1, unless they have a default value. Such fields get indices after
+// the last declared column, so readers can add their values to every row.
+// The indices are moved by SetDefaultsIdx when the real width of the data
+// files is known.
+func setFieldsIdx(fs []Field, idIdx int) error {
+	var err error
 	for i := range fs {
 		if fs[i].Index == "" {
 			fs[i].Idx = -1
@@ -42,33 +72,42 @@ func New(r io.Reader) (*Meta, error) {
 		}
 		fs[i].Idx, err = strconv.Atoi(fs[i].Index)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
+	SetDefaultsIdx(fs, idIdx, 0)
+	return nil
+}
 
-	for i := range res.Extensions {
-		if res.Extensions[i].CoreID.Index == "" {
-			res.Extensions[i].CoreID.Idx = -1
-			continue
-		}
-		res.Extensions[i].CoreID.Idx, err = strconv.Atoi(res.Extensions[i].CoreID.Index)
-		if err != nil {
-			return nil, err
+// SetDefaultsIdx sets indices of fields that have only default values.
+// Such fields are placed after the declared fields, the ID field and the
+// given width of data rows, so they do not overlap with columns that
+// exist in data files but are not declared in meta.xml.
+func SetDefaultsIdx(fs []Field, idIdx, width int) {
+	maxIdx := max(idIdx, width-1)
+	for i := range fs {
+		if fs[i].Index != "" {
+			maxIdx = max(maxIdx, fs[i].Idx)
 		}
-		fs := res.Extensions[i].Fields
-		for j := range fs {
-			if fs[j].Index == "" {
-				fs[j].Idx = -1
-				continue
-			}
-			fs[j].Idx, err = strconv.Atoi(fs[j].Index)
-			if err != nil {
-				return nil, err
-			}
+	}
+
+	for i := range fs {
+		if fs[i].Index == "" && fs[i].Default != "" {
+			maxIdx++
+			fs[i].Idx = maxIdx
 		}
 	}
+}
 
-	return &res, nil
+// HasDefaultsOnly checks if some of the fields have only default values
+// without an index.
+func HasDefaultsOnly(fs []Field) bool {
+	for _, v := range fs {
+		if v.Index == "" && v.Default != "" {
+			return true
+		}
+	}
+	return false
 }
 
 func (m *Meta) Bytes() ([]byte, error) {
@@ -110,6 +149,7 @@ func (c *Core) toCoreData() CoreData {
 	coreData := CoreData{
 		Index:      idx,
 		Location:   c.Files.Location,
+		Locations:  c.Files.Paths(),
 		TermFull:   termFull,
 		Term:       term,
 		FieldsData: make(map[string]FieldData),
@@ -117,7 +157,7 @@ func (c *Core) toCoreData() CoreData {
 	}
 
 	for _, field := range c.Fields {
-		idx = 0
+		idx = field.Idx
 		idxRes, err := strconv.Atoi(field.Index)
 
 		if err == nil {
@@ -146,12 +186,13 @@ func (e *Extension) toExtensionData() ExtensionData {
 	extData := ExtensionData{
 		CoreIndex:  idx,
 		Location:   e.Files.Location,
+		Locations:  e.Files.Paths(),
 		FieldsData: make(map[string]FieldData),
 		FieldsIdx:  make(map[int]FieldData),
 	}
 	for _, field := range e.Fields {
 		term := filepath.Base(field.Term)
-		idx = 0
+		idx = field.Idx
 		idxRes, err := strconv.Atoi(field.Index)
 		if err == nil {
 			idx = idxRes
@@ -0,0 +1,240 @@
+// package tree provides navigation through the taxonomic tree of a
+// checklist.
+package tree
+
+// Node is a taxon of the tree.
+type Node struct {
+	// ID is the identifier of the taxon.
+	ID string `json:"id"`
+
+	// ParentID is the identifier of the parent taxon as it is given in
+	// the data.
+	ParentID string `json:"parentId,omitempty"`
+
+	// AcceptedID is the identifier of the accepted taxon. It is empty
+	// for accepted taxa.
+	AcceptedID string `json:"acceptedId,omitempty"`
+
+	// Name is the scientific name of the taxon.
+	Name string `json:"name"`
+
+	// Rank is the lowercased rank of the taxon.
+	Rank string `json:"rank,omitempty"`
+
+	// RowNum is the number of the taxon's row among data rows of the Core,
+	// counted from 1. Header lines and skipped bad rows are not counted.
+	RowNum int `json:"rowNum"`
+}
+
+// IsSynonym checks if the node is a synonym of another taxon.
+func (n Node) IsSynonym() bool {
+	return n.AcceptedID != ""
+}
+
+// Tree is a taxonomic tree built from parent and accepted taxon
+// references of a checklist. Synonyms are not a part of the parent-child
+// tree, they are attached to their accepted taxa.
+type Tree struct {
+	nodes    []Node
+	ids      map[string]int
+	children map[string][]int
+	synonyms map[string][]int
+	roots    []int
+}
+
+// New creates a tree from nodes. If several nodes have the same ID, only
+// the first one is used. Accepted taxa without a parent, or with a parent
+// that does not exist, become roots of the tree.
+func New(nodes []Node) *Tree {
+	res := &Tree{
+		ids:      make(map[string]int),
+		children: make(map[string][]int),
+		synonyms: make(map[string][]int),
+	}
+	for _, v := range nodes {
+		if v.ID == "" {
+			continue
+		}
+		if _, ok := res.ids[v.ID]; ok {
+			continue
+		}
+		if v.AcceptedID == v.ID {
+			v.AcceptedID = ""
+		}
+		res.ids[v.ID] = len(res.nodes)
+		res.nodes = append(res.nodes, v)
+	}
+
+	for i, v := range res.nodes {
+		if v.IsSynonym() {
+			res.synonyms[v.AcceptedID] = append(res.synonyms[v.AcceptedID], i)
+			continue
+		}
+		if _, ok := res.ids[v.ParentID]; !ok || v.ParentID == v.ID {
+			res.roots = append(res.roots, i)
+			continue
+		}
+		res.children[v.ParentID] = append(res.children[v.ParentID], i)
+	}
+	return res
+}
+
+// Len returns the number of nodes in the tree, including synonyms.
+func (t *Tree) Len() int {
+	return len(t.nodes)
+}
+
+// Node returns the node with the given ID.
+func (t *Tree) Node(id string) (Node, bool) {
+	idx, ok := t.ids[id]
+	if !ok {
+		return Node{}, false
+	}
+	return t.nodes[idx], true
+}
+
+// Roots returns accepted taxa that have no parents.
+func (t *Tree) Roots() []Node {
+	return t.list(t.roots)
+}
+
+// Children returns accepted taxa that are direct children of the taxon
+// with the given ID.
+func (t *Tree) Children(id string) []Node {
+	return t.list(t.children[id])
+}
+
+// Synonyms returns synonyms of the taxon with the given ID.
+func (t *Tree) Synonyms(id string) []Node {
+	return t.list(t.synonyms[id])
+}
+
+// Ancestors returns ancestors of the taxon with the given ID, starting
+// from its parent and finishing with a root. For a synonym, its accepted
+// taxon is returned first, followed by the ancestors of the accepted
+// taxon. Walking stops if the hierarchy has a cycle.
+func (t *Tree) Ancestors(id string) []Node {
+	node, ok := t.Node(id)
+	if !ok {
+		return nil
+	}
+
+	var res []Node
+	seen := map[string]struct{}{id: {}}
+	if node.IsSynonym() {
+		acc, ok := t.Node(node.AcceptedID)
+		if !ok {
+			return nil
+		}
+		res = append(res, acc)
+		seen[acc.ID] = struct{}{}
+		node = acc
+	}
+
+	for {
+		parent, ok := t.Node(node.ParentID)
+		if !ok || parent.IsSynonym() {
+			return res
+		}
+		if _, ok := seen[parent.ID]; ok {
+			return res
+		}
+		seen[parent.ID] = struct{}{}
+		res = append(res, parent)
+		node = parent
+	}
+}
+
+// Descendants returns all accepted taxa below the taxon with the given
+// ID. Every taxon is followed by its own descendants.
+func (t *Tree) Descendants(id string) []Node {
+	var res []Node
+	t.walk(id, 0, func(idx, _ int) {
+		res = append(res, t.nodes[idx])
+	})
+	return res
+}
+
+// Depth returns the number of ancestors of the taxon with the given ID.
+// Roots have depth 0. It returns -1 if there is no such taxon.
+func (t *Tree) Depth(id string) int {
+	if _, ok := t.ids[id]; !ok {
+		return -1
+	}
+	return len(t.Ancestors(id))
+}
+
+// RankCounts returns the number of accepted taxa of every rank below the
+// taxon with the given ID. If the ID is empty, all accepted taxa of the
+// tree are counted. Taxa without a rank are counted with an empty key.
+func (t *Tree) RankCounts(id string) map[string]int {
+	res := make(map[string]int)
+	if id != "" {
+		t.walk(id, 0, func(idx, _ int) {
+			res[t.nodes[idx].Rank]++
+		})
+		return res
+	}
+
+	for _, v := range t.nodes {
+		if !v.IsSynonym() {
+			res[v.Rank]++
+		}
+	}
+	return res
+}
+
+// DepthCounts returns the number of accepted taxa at every depth of the
+// tree, starting from roots at depth 0. Taxa that cannot be reached from
+// roots because of cycles in the hierarchy are not counted.
+func (t *Tree) DepthCounts() map[int]int {
+	res := make(map[int]int)
+	for _, v := range t.roots {
+		res[0]++
+		t.walk(t.nodes[v].ID, 1, func(_, depth int) {
+			res[depth]++
+		})
+	}
+	return res
+}
+
+// walk visits accepted descendants of the taxon with the given ID in
+// depth-first order. Children of the taxon have the given depth.
+func (t *Tree) walk(id string, depth int, fn func(idx, depth int)) {
+	type item struct{ idx, depth int }
+
+	seen := map[string]struct{}{id: {}}
+	var stack []item
+	push := func(id string, depth int) {
+		ch := t.children[id]
+		// children are pushed in reverse order to visit them in the
+		// original one.
+		for i := len(ch) - 1; i >= 0; i-- {
+			stack = append(stack, item{idx: ch[i], depth: depth})
+		}
+	}
+
+	push(id, depth)
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		node := t.nodes[it.idx]
+		if _, ok := seen[node.ID]; ok {
+			continue
+		}
+		seen[node.ID] = struct{}{}
+		fn(it.idx, it.depth)
+		push(node.ID, it.depth+1)
+	}
+}
+
+func (t *Tree) list(idxs []int) []Node {
+	if len(idxs) == 0 {
+		return nil
+	}
+	res := make([]Node, len(idxs))
+	for i, v := range idxs {
+		res[i] = t.nodes[v]
+	}
+	return res
+}
@@ -0,0 +1,87 @@
+package tree_test
+
+import (
+	"testing"
+
+	"github.com/gnames/dwca/pkg/ent/tree"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTree() *tree.Tree {
+	return tree.New([]tree.Node{
+		{ID: "1", Name: "Animalia", Rank: "kingdom"},
+		{ID: "2", ParentID: "1", Name: "Chordata", Rank: "phylum"},
+		{ID: "3", ParentID: "2", Name: "Aves", Rank: "class"},
+		{ID: "4", ParentID: "2", Name: "Mammalia", Rank: "class"},
+		{ID: "5", ParentID: "1", Name: "Arthropoda", Rank: "phylum"},
+		// accepted taxon that refers to itself
+		{ID: "6", ParentID: "2", AcceptedID: "6", Name: "Vertebrata"},
+		{ID: "7", AcceptedID: "4", Name: "Mammalae", Rank: "class"},
+		{ID: "8", ParentID: "404", Name: "Plantae", Rank: "kingdom"},
+		// cycle
+		{ID: "9", ParentID: "10", Name: "Aus", Rank: "genus"},
+		{ID: "10", ParentID: "9", Name: "Bus", Rank: "genus"},
+		// duplicate ID
+		{ID: "3", ParentID: "1", Name: "Duplicate"},
+	})
+}
+
+func ids(nodes []tree.Node) []string {
+	var res []string
+	for _, v := range nodes {
+		res = append(res, v.ID)
+	}
+	return res
+}
+
+func TestTree(t *testing.T) {
+	assert := assert.New(t)
+	tr := testTree()
+	assert.Equal(10, tr.Len())
+
+	node, ok := tr.Node("3")
+	assert.True(ok)
+	assert.Equal("Aves", node.Name)
+	node, ok = tr.Node("6")
+	assert.True(ok)
+	assert.False(node.IsSynonym())
+	_, ok = tr.Node("404")
+	assert.False(ok)
+
+	tests := []struct {
+		msg string
+		fn  func(string) []tree.Node
+		id  string
+		res []string
+	}{
+		{"children", tr.Children, "2", []string{"3", "4", "6"}},
+		{"no children", tr.Children, "3", nil},
+		{"synonyms", tr.Synonyms, "4", []string{"7"}},
+		{"no synonyms", tr.Synonyms, "3", nil},
+		{"ancestors", tr.Ancestors, "3", []string{"2", "1"}},
+		{"root ancestors", tr.Ancestors, "1", nil},
+		{"synonym ancestors", tr.Ancestors, "7", []string{"4", "2", "1"}},
+		{"cycle ancestors", tr.Ancestors, "9", []string{"10"}},
+		{"unknown ancestors", tr.Ancestors, "404", nil},
+		{"descendants", tr.Descendants, "1", []string{"2", "3", "4", "6", "5"}},
+		{"cycle descendants", tr.Descendants, "9", []string{"10"}},
+	}
+	for _, v := range tests {
+		assert.Equal(v.res, ids(v.fn(v.id)), v.msg)
+	}
+
+	assert.Equal([]string{"1", "8"}, ids(tr.Roots()))
+	assert.Equal(2, tr.Depth("3"))
+	assert.Equal(0, tr.Depth("8"))
+	assert.Equal(-1, tr.Depth("404"))
+
+	assert.Equal(
+		map[string]int{"phylum": 2, "class": 2, "": 1},
+		tr.RankCounts("1"),
+	)
+	assert.Equal(
+		map[string]int{"kingdom": 2, "phylum": 2, "class": 2, "genus": 2, "": 1},
+		tr.RankCounts(""),
+	)
+	assert.Equal(map[int]int{0: 2, 1: 2, 2: 3}, tr.DepthCounts())
+}
@@ -0,0 +1,98 @@
+package valid
+
+// IssueType is a type of a problem found during validation.
+type IssueType int
+
+const (
+	UnknownIssue IssueType = iota
+
+	// MissingFile means that a file from meta.xml does not exist.
+	MissingFile
+
+	// ReadError means that a file cannot be read.
+	ReadError
+
+	// FieldIndex means that a field index from meta.xml is beyond the
+	// width of a row.
+	FieldIndex
+
+	// DuplicateID means that an ID of the Core is not unique.
+	DuplicateID
+
+	// OrphanCoreID means that a coreid of an extension does not exist in
+	// the Core.
+	OrphanCoreID
+
+	// BrokenParentID means that parentNameUsageID (or higherTaxonID)
+	// refers to a non-existent record.
+	BrokenParentID
+
+	// BrokenAcceptedID means that acceptedNameUsageID refers to a
+	// non-existent record.
+	BrokenAcceptedID
+
+	// HierarchyCycle means that a record is its own ancestor.
+	HierarchyCycle
+
+	// EmptyName means that a record has no scientific name.
+	EmptyName
+)
+
+var issueTypeStr = map[IssueType]string{
+	UnknownIssue:     "unknown",
+	MissingFile:      "missing-file",
+	ReadError:        "read-error",
+	FieldIndex:       "field-index-out-of-range",
+	DuplicateID:      "duplicate-id",
+	OrphanCoreID:     "orphan-coreid",
+	BrokenParentID:   "broken-parent-id",
+	BrokenAcceptedID: "broken-accepted-id",
+	HierarchyCycle:   "hierarchy-cycle",
+	EmptyName:        "empty-scientific-name",
+}
+
+var issueTypeDesc = map[IssueType]string{
+	UnknownIssue:     "Unknown problem",
+	MissingFile:      "File does not exist",
+	ReadError:        "Cannot read file",
+	FieldIndex:       "Field index is beyond row width",
+	DuplicateID:      "Duplicate core ID",
+	OrphanCoreID:     "Core ID does not exist in core",
+	BrokenParentID:   "Parent ID does not exist in core",
+	BrokenAcceptedID: "Accepted ID does not exist in core",
+	HierarchyCycle:   "Cycle in hierarchy",
+	EmptyName:        "Empty scientific name",
+}
+
+func (t IssueType) String() string {
+	if res, ok := issueTypeStr[t]; ok {
+		return res
+	}
+	return issueTypeStr[UnknownIssue]
+}
+
+// Description returns a human-readable description of the issue type.
+func (t IssueType) Description() string {
+	if res, ok := issueTypeDesc[t]; ok {
+		return res
+	}
+	return issueTypeDesc[UnknownIssue]
+}
+
+// MarshalText implements encoding.TextMarshaler interface, so the issue
+// type is represented as a string in JSON.
+func (t IssueType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface.
+func (t *IssueType) UnmarshalText(bs []byte) error {
+	*t = UnknownIssue
+	for k, v := range issueTypeStr {
+		if v == string(bs) {
+			*t = k
+			break
+		}
+	}
+	return nil
+}
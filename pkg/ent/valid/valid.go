@@ -0,0 +1,132 @@
+// package valid contains the report of DwCA archive validation.
+package valid
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SampleSize is the maximal number of row numbers that are kept as
+// examples for an issue.
+const SampleSize = 10
+
+// Report contains results of DwCA archive validation.
+type Report struct {
+	// Valid is true if no issues were found.
+	Valid bool `json:"valid"`
+
+	// Files contains information about data files of the archive.
+	Files []File `json:"files"`
+
+	// Issues contains problems found in the archive.
+	Issues []Issue `json:"issues"`
+}
+
+// File contains information about a data file of the archive.
+type File struct {
+	// Name is the location of the file according to meta.xml.
+	Name string `json:"name"`
+
+	// RowType is the row type of the file according to meta.xml.
+	RowType string `json:"rowType"`
+
+	// RowsNum is the number of data rows in the file.
+	RowsNum int `json:"rowsNum"`
+}
+
+// Issue contains information about one type of problem found in one file.
+type Issue struct {
+	// Type is the type of the problem.
+	Type IssueType `json:"type"`
+
+	// File is the data file where the problem was found.
+	File string `json:"file"`
+
+	// Count is the number of times the problem was found.
+	Count int `json:"count"`
+
+	// Rows contains examples of row numbers where the problem was found.
+	// Rows are counted from 1, header lines are not counted.
+	Rows []int `json:"sampleRows,omitempty"`
+
+	// Message contains details about the problem, if they are available.
+	Message string `json:"message,omitempty"`
+}
+
+// New creates a new empty Report.
+func New() *Report {
+	return &Report{Valid: true, Files: []File{}, Issues: []Issue{}}
+}
+
+// Add registers a problem of a given type found in a row of a file.
+// Problems of the same type in the same file are merged into one Issue.
+func (r *Report) Add(typ IssueType, file string, row int) {
+	r.Valid = false
+	for i := range r.Issues {
+		iss := &r.Issues[i]
+		if iss.Type != typ || iss.File != file || iss.Message != "" {
+			continue
+		}
+		iss.Count++
+		if len(iss.Rows) < SampleSize {
+			iss.Rows = append(iss.Rows, row)
+		}
+		return
+	}
+	r.Issues = append(r.Issues, Issue{
+		Type:  typ,
+		File:  file,
+		Count: 1,
+		Rows:  []int{row},
+	})
+}
+
+// AddMessage registers a problem that is not related to a specific row.
+func (r *Report) AddMessage(typ IssueType, file, msg string) {
+	r.Valid = false
+	r.Issues = append(r.Issues, Issue{
+		Type:    typ,
+		File:    file,
+		Count:   1,
+		Message: msg,
+	})
+}
+
+// JSON returns the report in JSON format.
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Text returns the report in a human-readable format.
+func (r *Report) Text() string {
+	var sb strings.Builder
+	status := "VALID"
+	if !r.Valid {
+		status = "INVALID"
+	}
+	fmt.Fprintf(&sb, "Status: %s\n\nFiles:\n", status)
+	for _, v := range r.Files {
+		fmt.Fprintf(&sb, "  %s (%s): %d rows\n", v.Name, v.RowType, v.RowsNum)
+	}
+
+	if len(r.Issues) == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString("\nIssues:\n")
+	for _, v := range r.Issues {
+		fmt.Fprintf(&sb, "  %s in %s: %d\n", v.Type.Description(), v.File, v.Count)
+		if v.Message != "" {
+			fmt.Fprintf(&sb, "    %s\n", v.Message)
+		}
+		if len(v.Rows) > 0 {
+			rows := make([]string, len(v.Rows))
+			for i := range v.Rows {
+				rows[i] = fmt.Sprintf("%d", v.Rows[i])
+			}
+			fmt.Fprintf(&sb, "    rows: %s\n", strings.Join(rows, ", "))
+		}
+	}
+	return sb.String()
+}
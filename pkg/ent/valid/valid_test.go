@@ -0,0 +1,38 @@
+package valid_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gnames/dwca/pkg/ent/valid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	assert := assert.New(t)
+	res := valid.New()
+	assert.True(res.Valid)
+
+	for i := range valid.SampleSize + 5 {
+		res.Add(valid.DuplicateID, "taxa.txt", i+1)
+	}
+	res.Add(valid.EmptyName, "taxa.txt", 3)
+	res.AddMessage(valid.MissingFile, "vern.txt", "no such file")
+	assert.False(res.Valid)
+	assert.Equal(3, len(res.Issues))
+	assert.Equal(valid.SampleSize+5, res.Issues[0].Count)
+	assert.Equal(valid.SampleSize, len(res.Issues[0].Rows))
+
+	bs, err := res.JSON()
+	assert.Nil(err)
+	var res2 valid.Report
+	err = json.Unmarshal(bs, &res2)
+	assert.Nil(err)
+	assert.Equal(*res, res2)
+	assert.Contains(string(bs), `"type": "duplicate-id"`)
+
+	txt := res.Text()
+	assert.Contains(txt, "Status: INVALID")
+	assert.Contains(txt, "Duplicate core ID in taxa.txt: 15")
+	assert.Contains(txt, "no such file")
+}
@@ -0,0 +1,96 @@
+package dwca
+
+import "fmt"
+
+// ErrRowSize is returned when the number of fields in a row does not
+// correspond to the number of fields declared for the file.
+type ErrRowSize struct {
+	// File is the name of the file the row was written to.
+	File string
+
+	// FieldsNum is the expected number of fields.
+	FieldsNum int
+
+	// RowFieldsNum is the number of fields in the row.
+	RowFieldsNum int
+}
+
+func (e *ErrRowSize) Error() string {
+	return fmt.Sprintf(
+		"file '%s' expects %d fields, got %d",
+		e.File, e.FieldsNum, e.RowFieldsNum,
+	)
+}
+
+// ErrWriter is returned when the Writer is used in a wrong order, for
+// example when rows are added after the archive was created.
+type ErrWriter struct {
+	Msg string
+}
+
+func (e *ErrWriter) Error() string {
+	return fmt.Sprintf("writer error: %s", e.Msg)
+}
+
+// ErrExtension is returned when an extension cannot be processed.
+type ErrExtension struct {
+	// Index is the index of the extension in meta.xml.
+	Index int
+
+	// RowType is the row type of the extension.
+	RowType string
+
+	// File is the location of the extension file.
+	File string
+
+	// Err is the original error.
+	Err error
+}
+
+func (e *ErrExtension) Error() string {
+	return fmt.Sprintf(
+		"cannot process extension %d '%s' (%s): %v",
+		e.Index, e.RowType, e.File, e.Err,
+	)
+}
+
+func (e *ErrExtension) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnknownRowType is returned when the archive has no extension with
+// the given row type.
+type ErrUnknownRowType struct {
+	RowType string
+}
+
+func (e *ErrUnknownRowType) Error() string {
+	return fmt.Sprintf("extension with row type '%s' is not found", e.RowType)
+}
+
+// ErrRecordNotFound is returned when the archive has no Core record with
+// the given ID.
+type ErrRecordNotFound struct {
+	ID string
+}
+
+func (e *ErrRecordNotFound) Error() string {
+	return fmt.Sprintf("record with ID '%s' is not found", e.ID)
+}
+
+// ErrExport is returned when an archive cannot be exported to a database.
+type ErrExport struct {
+	// Path is the location of the database.
+	Path string
+
+	// Err is the original error.
+	Err error
+}
+
+func (e *ErrExport) Error() string {
+	return fmt.Sprintf("cannot export archive to '%s': %v", e.Path, e.Err)
+}
+
+func (e *ErrExport) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,219 @@
+package dwca
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/gnames/dwca/pkg/ent/meta"
+	"golang.org/x/sync/errgroup"
+)
+
+// metaTable describes columns of exported data tables.
+var metaTable = ent.SQLTable{
+	Name: "meta",
+	Columns: []string{
+		"table_name", "row_type", "column_name", "term", "field_index",
+		"default_value",
+	},
+	Indices: []string{"table_name"},
+}
+
+// emlTable keeps EML data of the archive as JSON.
+var emlTable = ent.SQLTable{
+	Name:    "eml",
+	Columns: []string{"json"},
+}
+
+// ExportSQLite saves the Core and all extensions of the archive to an
+// SQLite database.
+func (a *arch) ExportSQLite(ctx context.Context, path string) error {
+	if a.meta == nil || a.meta.Core == nil {
+		return errors.New("archive is not loaded")
+	}
+
+	w, err := newSQLWriter(path)
+	if err != nil {
+		return &ErrExport{Path: path, Err: err}
+	}
+
+	err = a.exportSQL(ctx, w)
+	errClose := w.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return &ErrExport{Path: path, Err: err}
+	}
+	return nil
+}
+
+func (a *arch) exportSQL(ctx context.Context, w ent.SQLWriter) error {
+	// table names must not clash with the service tables.
+	names := map[string]struct{}{
+		metaTable.Name: {},
+		emlTable.Name:  {},
+	}
+	var metaRows [][]string
+
+	core := a.meta.Core
+	coreTable, rows := sqlTable(
+		names, core.RowType, core.ID.Idx, core.ID.Term, "", core.Fields,
+	)
+	metaRows = append(metaRows, rows...)
+	err := exportTable(ctx, w, coreTable, a.CoreStream)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range a.meta.Extensions {
+		t, rows := sqlTable(
+			names, v.RowType, v.CoreID.Idx, "", "coreid", v.Fields,
+		)
+		metaRows = append(metaRows, rows...)
+		stream := func(ctx context.Context, ch chan<- []string) (int, error) {
+			return a.ExtensionStream(ctx, i, ch)
+		}
+		err = exportTable(ctx, w, t, stream)
+		if err != nil {
+			return &ErrExtension{
+				Index:   i,
+				RowType: v.RowType,
+				File:    v.Files.Location,
+				Err:     err,
+			}
+		}
+	}
+
+	err = exportRows(ctx, w, metaTable, metaRows)
+	if err != nil {
+		return err
+	}
+
+	var emlRows [][]string
+	if a.emlData != nil {
+		bs, err := json.Marshal(a.emlData)
+		if err != nil {
+			return err
+		}
+		emlRows = append(emlRows, []string{string(bs)})
+	}
+	return exportRows(ctx, w, emlTable, emlRows)
+}
+
+// exportTable creates a table and fills it with rows from the stream.
+func exportTable(
+	ctx context.Context,
+	w ent.SQLWriter,
+	t ent.SQLTable,
+	stream func(context.Context, chan<- []string) (int, error),
+) error {
+	err := w.CreateTable(t)
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan []string)
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		_, err := stream(ctx, ch)
+		return err
+	})
+	g.Go(func() error {
+		_, err := w.Write(ctx, t, ch)
+		return err
+	})
+
+	err = g.Wait()
+	if err != nil {
+		return err
+	}
+	return w.CreateIndices(t)
+}
+
+// exportRows creates a table with the given rows.
+func exportRows(
+	ctx context.Context,
+	w ent.SQLWriter,
+	t ent.SQLTable,
+	rows [][]string,
+) error {
+	stream := func(_ context.Context, ch chan<- []string) (int, error) {
+		defer close(ch)
+		for _, v := range rows {
+			ch <- v
+		}
+		return len(rows), nil
+	}
+	return exportTable(ctx, w, t, stream)
+}
+
+// sqlTable creates a table description for a data file, and rows of the
+// meta table for its columns. Columns are named by meta.Headers, the ID
+// column without a field gets idName, if it is not empty. Names of tables
+// and columns are made unique, because SQLite ignores their case.
+func sqlTable(
+	names map[string]struct{},
+	rowType string,
+	idIdx int,
+	idTerm, idName string,
+	fields []meta.Field,
+) (ent.SQLTable, [][]string) {
+	res := ent.SQLTable{Name: uniqueName(names, path.Base(rowType))}
+
+	terms := make(map[int]meta.Field)
+	for _, v := range fields {
+		terms[v.Idx] = v
+	}
+
+	var headers []string
+	if len(fields) > 0 {
+		headers = meta.Headers(idIdx, fields)
+	}
+	for len(headers) <= idIdx {
+		headers = append(headers, "unknown"+strconv.Itoa(len(headers)+1))
+	}
+
+	columns := make(map[string]struct{})
+	var rows [][]string
+	for i, v := range headers {
+		f, ok := terms[i]
+		if !ok && i == idIdx {
+			f.Term = idTerm
+			if idName != "" {
+				v = idName
+			}
+		}
+		col := uniqueName(columns, v)
+		res.Columns = append(res.Columns, col)
+
+		if i == idIdx || strings.EqualFold(path.Base(f.Term), "taxonID") {
+			res.Indices = append(res.Indices, col)
+		}
+		rows = append(rows, []string{
+			res.Name, rowType, col, f.Term, strconv.Itoa(i), f.Default,
+		})
+	}
+	return res, rows
+}
+
+// uniqueName adds a numeric suffix to the name, if a case-insensitive
+// version of the name is already taken.
+func uniqueName(names map[string]struct{}, name string) string {
+	if name == "" || name == "." || name == "/" {
+		name = "unknown"
+	}
+	res := name
+	for i := 2; ; i++ {
+		key := strings.ToLower(res)
+		if _, ok := names[key]; !ok {
+			names[key] = struct{}{}
+			return res
+		}
+		res = name + "_" + strconv.Itoa(i)
+	}
+}
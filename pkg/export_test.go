@@ -0,0 +1,84 @@
+package dwca_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/gnfmt"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestExportSQLite(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(filepath.Join("testdata", "data.tar.gz"), cfg)
+	assert.Nil(err)
+	defer arc.Close()
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	path := filepath.Join(t.TempDir(), "dwca.sqlite")
+	err = arc.ExportSQLite(context.Background(), path)
+	assert.Nil(err)
+	// existing database is replaced
+	err = arc.ExportSQLite(context.Background(), path)
+	assert.Nil(err)
+
+	db, err := sql.Open("sqlite", path)
+	assert.Nil(err)
+	defer db.Close()
+
+	var count int
+	err = db.QueryRow(`SELECT count(*) FROM DarwinCore`).Scan(&count)
+	assert.Nil(err)
+	assert.Equal(587, count)
+
+	var name, rank string
+	err = db.QueryRow(
+		`SELECT scientificName, taxonRank FROM DarwinCore WHERE taxonID = ?`,
+		"leptogastrinae:tid:42",
+	).Scan(&name, &rank)
+	assert.Nil(err)
+	assert.Equal("Leptogastrinae", name)
+	assert.Equal("subfamily", rank)
+
+	var vern string
+	err = db.QueryRow(
+		`SELECT vernacularName FROM VernacularName WHERE coreid = ?`,
+		"leptogastrinae:tid:42",
+	).Scan(&vern)
+	assert.Nil(err)
+	assert.Equal("Grass flies", vern)
+
+	var term string
+	err = db.QueryRow(
+		`SELECT term FROM meta
+		WHERE table_name = 'DarwinCore' AND column_name = 'ScientificName'`,
+	).Scan(&term)
+	assert.Nil(err)
+	assert.Equal("http://rs.tdwg.org/dwc/terms/ScientificName", term)
+
+	var js string
+	err = db.QueryRow(`SELECT json FROM eml`).Scan(&js)
+	assert.Nil(err)
+	assert.Contains(js, "Leptogastrinae")
+
+	var idxs []string
+	rows, err := db.Query(
+		`SELECT name FROM sqlite_master WHERE type = 'index' ORDER BY name`,
+	)
+	assert.Nil(err)
+	defer rows.Close()
+	for rows.Next() {
+		var s string
+		assert.Nil(rows.Scan(&s))
+		idxs = append(idxs, s)
+	}
+	assert.Contains(idxs, "DarwinCore_taxonID_idx")
+	assert.Contains(idxs, "VernacularName_coreid_idx")
+}
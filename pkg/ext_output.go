@@ -2,51 +2,170 @@ package dwca
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"log/slog"
+	"path"
 	"path/filepath"
+	"sync"
 
+	"github.com/gnames/dwca/internal/ent/dcfile"
 	"github.com/gnames/dwca/pkg/ent/meta"
 	"golang.org/x/sync/errgroup"
 )
 
 func (a *arch) processExtensionsOutput() error {
+	synIdx := -1
+	if !a.isNormalized() && a.isSynExtension() {
+		synIdx = a.synExtIdx()
+	}
+
+	abort := a.cfg.ExtErrorPolicy != "continue"
+	var errs []error
+	failed := make(map[int]struct{})
+
+	// extensions with missing data files fail before processing.
+	missing, err := a.missingExtensions()
+	if err != nil {
+		return err
+	}
+	for _, err := range missing {
+		if err.Index == synIdx {
+			continue
+		}
+		if abort {
+			return err
+		}
+		slog.Warn("Extension is excluded from output", "error", err)
+		errs = append(errs, err)
+		failed[err.Index] = struct{}{}
+	}
+
+	g, ctx := errgroup.WithContext(context.Background())
+	g.SetLimit(a.cfg.JobsNum)
+	var mx sync.Mutex
+
 	for i := range a.meta.Extensions {
-		a.processExt(i)
+		// synonyms from the extension are already moved to the Core.
+		if i == synIdx {
+			continue
+		}
+		if _, ok := failed[i]; ok {
+			continue
+		}
+		g.Go(func() error {
+			err := a.processExt(ctx, i)
+			if err == nil {
+				return nil
+			}
+			if abort {
+				return err
+			}
+			slog.Warn("Extension is excluded from output", "error", err)
+			mx.Lock()
+			errs = append(errs, err)
+			failed[i] = struct{}{}
+			mx.Unlock()
+			return nil
+		})
 	}
-	return nil
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// files of failed extensions are not a part of the output.
+	for i := range failed {
+		err = a.dcFile.RemoveOutput(a.outputMeta.Extensions[i].Files.Location)
+		if err != nil {
+			return err
+		}
+	}
+
+	var exts []*meta.Extension
+	for i, v := range a.outputMeta.Extensions {
+		if _, ok := failed[i]; !ok && i != synIdx {
+			exts = append(exts, v)
+		}
+	}
+	a.outputMeta.Extensions = exts
+
+	return errors.Join(errs...)
 }
 
-func (a *arch) processExt(idx int) {
-	ext := a.meta.Extensions[idx]
-	extType := ext.RowType
-	extType = filepath.Base(extType)
-	slog.Info("Processing extension", "ext", extType)
-	var maxIdx int
-	for _, v := range ext.Fields {
-		if v.Idx > maxIdx {
-			maxIdx = v.Idx
+// missingExtensions returns errors of extensions with missing data files
+// in the order of extensions.
+func (a *arch) missingExtensions() ([]*ErrExtension, error) {
+	fsys, dir, err := a.dcFile.FS(a.root)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*ErrExtension
+	for i, ext := range a.meta.Extensions {
+		for _, v := range ext.Files.Paths() {
+			_, err := fs.Stat(fsys, path.Join(dir, v))
+			if err != nil {
+				res = append(res, &ErrExtension{
+					Index:   i,
+					RowType: ext.RowType,
+					File:    v,
+					Err:     &dcfile.ErrFileNotFound{Path: v},
+				})
+				break
+			}
 		}
 	}
+	return res, nil
+}
+
+func (a *arch) processExt(ctx context.Context, idx int) error {
+	ext := a.meta.Extensions[idx]
+	extType := filepath.Base(ext.RowType)
+	slog.Info("Processing extension", "ext", extType)
 	a.updateOutputMetaExt(idx)
 
 	chIn := make(chan []string)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	g, ctx := errgroup.WithContext(ctx)
+	chOut := chIn
+	var eo *eventOccurrence
+	if a.isEventOccurrence(idx) {
+		// occurrences inherit fields from their events.
+		eo = a.newEventOccurrence(idx)
+		chOut = make(chan []string)
+		g.Go(func() error {
+			return a.eventOccurrenceOutput(ctx, eo, chIn, chOut)
+		})
+	}
 	g.Go(func() error {
-		return a.saveExtOutput(ctx, idx, chIn)
+		return a.saveExtOutput(ctx, idx, chOut)
 	})
 
 	_, err := a.ExtensionStream(ctx, idx, chIn)
+	if gErr := g.Wait(); gErr != nil && err == nil {
+		err = gErr
+	}
+	if eo != nil && err == nil {
+		eo.occ.logBadCoords(ext.Files.Location)
+	}
 	if err != nil {
 		slog.Error(
 			"Error processing extension",
 			"file", ext.Files.Location,
 			"error", err,
 		)
+		return &ErrExtension{
+			Index:   idx,
+			RowType: ext.RowType,
+			File:    ext.Files.Location,
+			Err:     err,
+		}
 	}
+	return nil
 }
 
 func (a *arch) saveExtOutput(
@@ -63,6 +182,7 @@ func (a *arch) saveExtOutput(
 
 func (a *arch) updateOutputMetaExt(idx int) {
 	ext := a.outputMeta.Extensions[idx]
+	setDefaultsIndex(ext.Fields)
 
 	file := ext.Files.Location
 	e := filepath.Ext(file)
@@ -74,6 +194,8 @@ func (a *arch) updateOutputMetaExt(idx int) {
 	}
 
 	ext.Files.Location = location
+	ext.Files.Locations = nil
+	ext.Encoding = "UTF-8"
 	ext.FieldsTerminatedBy = delim
 	ext.LinesTerminatedBy = `\n`
 	ext.FieldsEnclosedBy = `"`
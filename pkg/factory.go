@@ -1,46 +1,175 @@
 package dwca
 
 import (
+	"io"
+	"io/fs"
 	"log/slog"
+	"os"
 	"strings"
 
+	"github.com/gnames/dwca/internal/ent"
+	"github.com/gnames/dwca/internal/ent/dcfile"
 	"github.com/gnames/dwca/internal/io/dcfileio"
+	"github.com/gnames/dwca/internal/io/factory"
 	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/cache"
 	"github.com/gnames/gnsys"
 )
 
 // Factory creates a new DwCA object. It takes a list of options for the
 // configuration, and a path to the DwCA file. The path is used to initialize
 // the DwCA object, and the options are used to configure the object.
+// The path can also point to a directory with an already unpacked archive,
+// in which case the files are read in place.
 // This function is the only place where concrete IO objects are allowed.
 func Factory(fpath string, cfg config.Config) (Archive, error) {
 	slog.Info("Creating empty DwCA object", "input", fpath)
-	dcf, err := dcfileio.New(cfg, fpath)
-	if err != nil {
-		return nil, err
-	}
 
 	// empty fpath means we initialize normalized internal object.
-	if fpath != "" {
-		err = dcf.ResetTempDirs()
+	if fpath == "" {
+		dcf, err := dcfileio.New(cfg, fpath)
 		if err != nil {
 			return nil, err
 		}
+		return New(cfg, dcf), nil
+	}
+
+	orig := cfg
+	cfg, dcf, err := newDCFile(cfg, func(cfg config.Config) (dcfile.DCFile, error) {
+		return dcfileio.New(cfg, fpath)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if strings.HasPrefix(fpath, "http") {
 		fpath, err = gnsys.Download(fpath, cfg.DownloadPath, true)
 		if err != nil {
+			_ = dcf.Close()
 			return nil, err
 		}
 		dcf.SetFilePath(fpath)
 	}
 
-	res := New(cfg, dcf)
-	return res, nil
+	return newWorkArch(orig, cfg, dcf), nil
+}
+
+// FactoryFS creates a new DwCA object from a file system that contains
+// unpacked DwCA files, for example from embed.FS. The files are read
+// directly from the file system without copying them to disk.
+func FactoryFS(fsys fs.FS, cfg config.Config) (Archive, error) {
+	slog.Info("Creating empty DwCA object from fs.FS")
+	orig := cfg
+	cfg, dcf, err := newDCFile(cfg, func(cfg config.Config) (dcfile.DCFile, error) {
+		return dcfileio.NewFS(cfg, fsys)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newWorkArch(orig, cfg, dcf), nil
+}
+
+// FactoryReaderAt creates a new DwCA object from ZIP data of the given
+// size, for example an in-memory archive wrapped into bytes.Reader.
+// The archive is read without extraction to disk.
+func FactoryReaderAt(
+	r io.ReaderAt,
+	size int64,
+	cfg config.Config,
+) (Archive, error) {
+	slog.Info("Creating empty DwCA object from io.ReaderAt", "size", size)
+	orig := cfg
+	cfg, dcf, err := newDCFile(cfg, func(cfg config.Config) (dcfile.DCFile, error) {
+		return dcfileio.NewReaderAt(cfg, r, size)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newWorkArch(orig, cfg, dcf), nil
+}
+
+// newDCFile initializes a DCFile object and prepares its temporary
+// directories. With IsolateWorkDir option it creates a unique working
+// directory for the archive first, so several archives do not interfere
+// with each other. It returns the configuration with paths of the
+// archive.
+func newDCFile(
+	cfg config.Config,
+	create func(config.Config) (dcfile.DCFile, error),
+) (config.Config, dcfile.DCFile, error) {
+	var err error
+	if cfg.IsolateWorkDir {
+		cfg, err = dcfileio.NewWorkDir(cfg)
+		if err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	dcf, err := create(cfg)
+	if err != nil {
+		if cfg.IsolateWorkDir {
+			_ = os.RemoveAll(cfg.WorkPath)
+		}
+		return cfg, nil, err
+	}
+
+	err = dcf.ResetTempDirs()
+	if err != nil {
+		_ = dcf.Close()
+		return cfg, nil, err
+	}
+	return cfg, dcf, nil
+}
+
+// newWorkArch creates an Archive that keeps its files in the working
+// directory from cfg. Load accepts ExtractPath of the original
+// configuration as well, so callers of isolated archives can use their
+// own configuration for loading.
+func newWorkArch(orig, cfg config.Config, dcf dcfile.DCFile) Archive {
+	res := New(cfg, dcf).(*arch)
+	res.extractAlias = orig.ExtractPath
+	return res
 }
 
 // FactoryOutput creates a new DwCA object from normalized output DwCA.
+// With IsolateWorkDir option the configuration should be taken from the
+// archive that was normalized, for example with arc.Config(), to find its
+// output files.
 func FactoryOutput(cfg config.Config) (Archive, error) {
 	return Factory("", cfg)
 }
+
+// CacheList returns information about archives in the cache located in
+// cfg.CachePath, starting from the most recently used.
+func CacheList(cfg config.Config) ([]cache.Entry, error) {
+	return dcfileio.ListCache(cfg)
+}
+
+// CachePrune removes archives that were not used for longer than
+// cfg.CacheMaxAge from the cache, and then least recently used archives
+// until the size of the cache is within cfg.CacheMaxSize. Zero limits
+// are ignored. It returns removed entries.
+func CachePrune(cfg config.Config) ([]cache.Entry, error) {
+	return dcfileio.PruneCache(cfg)
+}
+
+// NewWriter creates a new Writer object that builds a DwCA file from
+// scratch. Data files are accumulated in the cfg.OutputPath directory, or
+// in the output directory of a unique working directory with
+// IsolateWorkDir option.
+func NewWriter(cfg config.Config) (Writer, error) {
+	cfg, dcf, err := newDCFile(cfg, func(cfg config.Config) (dcfile.DCFile, error) {
+		return dcfileio.New(cfg, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newWriter(cfg, dcf), nil
+}
+
+// newSQLWriter creates an SQLite database at the given path for exported
+// data of an archive.
+func newSQLWriter(path string) (ent.SQLWriter, error) {
+	return factory.SQLWriter(path)
+}
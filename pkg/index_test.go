@@ -0,0 +1,223 @@
+package dwca_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/gnfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoreByID(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(filepath.Join("testdata", "data.tar.gz"), cfg)
+	assert.Nil(err)
+	defer arc.Close()
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	rec, err := arc.CoreByID("leptogastrinae:tid:42")
+	assert.Nil(err)
+	assert.Equal(2, rec.RowNum)
+	assert.Equal("Leptogastrinae", rec.Get("scientificName"))
+	assert.Equal("subfamily", rec.Get("taxonRank"))
+
+	exts, err := arc.ExtensionsByCoreID("leptogastrinae:tid:42")
+	assert.Nil(err)
+	assert.Equal(1, len(exts))
+	idx, err := arc.ExtensionIndex("VernacularName")
+	assert.Nil(err)
+	assert.Equal(1, len(exts[idx]))
+	assert.Equal("Grass flies", exts[idx][0].Get("vernacularName"))
+
+	_, err = arc.CoreByID("nope")
+	var errNotFound *dwca.ErrRecordNotFound
+	assert.True(errors.As(err, &errNotFound))
+	exts, err = arc.ExtensionsByCoreID("nope")
+	assert.Nil(err)
+	assert.Empty(exts[0])
+}
+
+func TestIndexData(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		msg, file string
+		stream    bool
+	}{
+		{"tar.gz", "data.tar.gz", false},
+		{"stream", "data.tar.gz", true},
+		{"zip stream", "vascan.zip", true},
+		{"dialect", "dialect.tar.gz", false},
+		{"encoding", "encoding.tar.gz", false},
+		{"multi location", "multi_location.tar.gz", false},
+		{"defaults", "defaults.tar.gz", false},
+		{"directory", "unpacked", false},
+	}
+
+	for _, v := range tests {
+		opts := []config.Option{
+			config.OptWrongFieldsNum(gnfmt.ProcessBadRow),
+			config.OptStreamArchive(v.stream),
+		}
+		path := filepath.Join("testdata", v.file)
+
+		cfg := config.New(opts...)
+		arc, err := dwca.Factory(path, cfg)
+		assert.Nil(err, v.msg)
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err, v.msg)
+		core, err := arc.CoreSlice(0, 0)
+		assert.Nil(err, v.msg)
+		var exts [][][]string
+		for i := range arc.Meta().Extensions {
+			rows, err := arc.ExtensionSlice(i, 0, 0)
+			assert.Nil(err, v.msg)
+			exts = append(exts, rows)
+		}
+		// pages are the same with and without the index.
+		for _, off := range offsets(len(core)) {
+			rows, err := arc.CoreSlice(off, 3)
+			assert.Nil(err, v.msg)
+			assert.Equal(window(core, off, 3), rows, v.msg)
+		}
+		arc.Close()
+
+		opts = append(opts, config.OptIndexData(true))
+		cfg = config.New(opts...)
+		arc, err = dwca.Factory(path, cfg)
+		assert.Nil(err, v.msg)
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err, v.msg)
+
+		assert.Greater(len(core), 0, v.msg)
+		for _, lim := range []int{0, 1, 3, 10} {
+			for _, off := range offsets(len(core)) {
+				rows, err := arc.CoreSlice(off, lim)
+				assert.Nil(err, v.msg)
+				assert.Equal(window(core, off, lim), rows, v.msg)
+			}
+		}
+		for i, ext := range exts {
+			for _, off := range offsets(len(ext)) {
+				rows, err := arc.ExtensionSlice(i, off, 2)
+				assert.Nil(err, v.msg)
+				assert.Equal(window(ext, off, 2), rows, v.msg)
+			}
+		}
+
+		idIdx := arc.Meta().Core.ID.Idx
+		first := make(map[string]int)
+		for i := len(core) - 1; i >= 0 && idIdx >= 0; i-- {
+			first[core[i][idIdx]] = i
+		}
+		for _, i := range offsets(len(core)) {
+			if idIdx < 0 || i < 0 || i >= len(core) {
+				continue
+			}
+			i = first[core[i][idIdx]]
+			rec, err := arc.CoreByID(core[i][idIdx])
+			assert.Nil(err, v.msg)
+			assert.Equal(i+1, rec.RowNum, v.msg)
+			assert.Equal(core[i], rec.Row, v.msg)
+		}
+		arc.Close()
+	}
+}
+
+func TestIndexSkipBadRow(t *testing.T) {
+	assert := assert.New(t)
+	files := []string{
+		"csv-less.tar.gz", "csv-more.tar.gz", "tsv-less.tar.gz", "tsv-more.tar.gz",
+	}
+	for _, v := range files {
+		path := filepath.Join("testdata", "fldnum", v)
+		opts := []config.Option{config.OptWrongFieldsNum(gnfmt.SkipBadRow)}
+		cfg := config.New(opts...)
+		arc, err := dwca.Factory(path, cfg)
+		assert.Nil(err, v)
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err, v)
+		core, err := arc.CoreSlice(0, 0)
+		assert.Nil(err, v)
+		// skipped rows do not shift pages.
+		for _, off := range offsets(len(core)) {
+			rows, err := arc.CoreSlice(off, 3)
+			assert.Nil(err, v)
+			assert.Equal(window(core, off, 3), rows, v)
+		}
+		arc.Close()
+
+		cfg = config.New(append(opts, config.OptIndexData(true))...)
+		arc, err = dwca.Factory(path, cfg)
+		assert.Nil(err, v)
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err, v)
+		for _, off := range offsets(len(core)) {
+			rows, err := arc.CoreSlice(off, 3)
+			assert.Nil(err, v)
+			assert.Equal(window(core, off, 3), rows, v)
+		}
+		arc.Close()
+	}
+}
+
+func TestIndexCache(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	cfg := config.New(
+		config.OptRootPath(root),
+		config.OptUseCache(true),
+		config.OptWrongFieldsNum(gnfmt.ProcessBadRow),
+	)
+	path := filepath.Join("testdata", "data.tar.gz")
+
+	for range 2 {
+		arc, err := dwca.Factory(path, cfg)
+		assert.Nil(err)
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err)
+		rec, err := arc.CoreByID("leptogastrinae:tid:42")
+		assert.Nil(err)
+		assert.Equal("Leptogastrinae", rec.Get("scientificName"))
+		arc.Close()
+	}
+
+	es, err := dwca.CacheList(cfg)
+	assert.Nil(err)
+	assert.Equal(1, len(es))
+	files, err := os.ReadDir(es[0].Path)
+	assert.Nil(err)
+	var indexNum int
+	for _, v := range files {
+		if strings.HasPrefix(v.Name(), "index-") {
+			indexNum++
+		}
+	}
+	assert.Equal(1, indexNum)
+}
+
+// offsets returns a sample of offsets for slices of data with the given
+// number of rows.
+func offsets(rowsNum int) []int {
+	return []int{0, 1, 2, rowsNum / 2, rowsNum - 2, rowsNum - 1, rowsNum, rowsNum + 1}
+}
+
+func window(rows [][]string, offset, limit int) [][]string {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 
 	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/diagn"
 	"github.com/gnames/dwca/pkg/ent/eml"
 	"github.com/gnames/dwca/pkg/ent/meta"
+	"github.com/gnames/dwca/pkg/ent/tree"
+	"github.com/gnames/dwca/pkg/ent/valid"
 )
 
 // Archive is an interface for Darwin Core Archive objects.
@@ -19,8 +22,15 @@ type Archive interface {
 	// EML returns the EML object of the archive.
 	EML() *eml.EML
 
+	// Diagnostics returns information about how scientific names,
+	// synonyms and hierarchy are represented in the archive. It is
+	// available after Load.
+	Diagnostics() *diagn.Diagnostics
+
 	// Load extracts the archive and loads data for EML and Meta.
-	// Path determines internal location of the extracted archive.
+	// Path determines internal location of the extracted archive. Both
+	// ExtractPath of Config() and of the configuration given to a factory
+	// point to the extracted archive.
 	Load(path string) error
 
 	// Close cleans up temporary files.
@@ -52,9 +62,80 @@ type Archive interface {
 		index int, ch chan<- []string,
 	) (int, error)
 
+	// ExtensionIndex returns the index of the extension with the given row
+	// type. The row type can be a full URI or its last element, for
+	// example `VernacularName`.
+	ExtensionIndex(rowType string) (int, error)
+
+	// CoreRecords takes a channel and populates it with Core records that
+	// give access to values by terms. The channel is closed when the data
+	// is exhausted. It returns the number of records.
+	CoreRecords(ctx context.Context, ch chan<- Record) (int, error)
+
+	// CoreRecordSlice returns Core records according to the offset and
+	// limit, the same way as CoreSlice.
+	CoreRecordSlice(offset, limit int) ([]Record, error)
+
+	// ExtensionRecords takes a row type of an extension and a channel, and
+	// populates the channel with the extension records. The channel is
+	// closed when the data is exhausted. It returns the number of records.
+	ExtensionRecords(
+		ctx context.Context,
+		rowType string,
+		ch chan<- Record,
+	) (int, error)
+
+	// ExtensionRecordSlice returns records of the extension with the given
+	// row type according to the offset and limit, the same way as
+	// ExtensionSlice.
+	ExtensionRecordSlice(rowType string, offset, limit int) ([]Record, error)
+
+	// StarRecords takes a channel and populates it with Core records joined
+	// with records of all extensions that refer to them. Records come
+	// sorted by their IDs. Data files are sorted using temporary files, so
+	// archives can be larger than memory. The channel is closed when the
+	// data is exhausted. It returns the number of Core records.
+	StarRecords(ctx context.Context, ch chan<- StarRecord) (int, error)
+
+	// CoreByID returns the Core record with the given ID. If there are
+	// several records with the same ID, the first one is returned. It
+	// returns ErrRecordNotFound if there is no such record. Records are
+	// found using an index of data files that is built on the first call,
+	// unless it was built during Load.
+	CoreByID(id string) (Record, error)
+
+	// ExtensionsByCoreID returns records of all extensions that refer to
+	// the Core record with the given ID. Extensions are in the same order
+	// as in meta.xml, ExtensionIndex finds the position of an extension.
+	ExtensionsByCoreID(id string) ([][]Record, error)
+
+	// Tree creates a taxonomic tree from Core records using their parent
+	// and accepted taxon references. The tree allows to find children,
+	// ancestors, descendants and synonyms of taxa, and to count taxa by
+	// ranks and depths. It is created once and reused by following calls.
+	Tree(ctx context.Context) (*tree.Tree, error)
+
+	// Validate checks the archive for problems, like missing files,
+	// duplicate IDs, broken references between records or cycles in the
+	// hierarchy. It returns a report with counts of found problems and
+	// examples of row numbers where they occur.
+	Validate(ctx context.Context) (*valid.Report, error)
+
+	// ExportSQLite saves the Core and all extensions to an SQLite database
+	// at the given path, replacing an existing file. Every data file gets a
+	// table named after its row type, with columns named after the terms of
+	// its fields. The `meta` table describes the columns, the `eml` table
+	// keeps EML data as JSON. ID columns of the Core, its taxonID and
+	// coreid columns of extensions are indexed.
+	ExportSQLite(ctx context.Context, path string) error
+
 	// Normalize creates a normalized version of Darwin Core Archive
 	// with all known ambiguities resolved. The output is written to a file
-	// with the provided fileName.
+	// with the provided fileName. Extensions that cannot be processed
+	// produce ErrExtension errors. Depending on ExtErrorPolicy of the
+	// configuration, such errors either stop normalization, or are
+	// returned after the normalized archive is created without the failed
+	// extensions.
 	Normalize() error
 
 	// ZipNorgalized compresses a normalized version of Darwin Core Archive
@@ -65,3 +146,38 @@ type Archive interface {
 	// to a TAR file with the provided filePath.
 	TarGzNormalized(filePath string) error
 }
+
+// Writer is an interface for creating Darwin Core Archive files from
+// scratch. Fields are provided as term URIs, for example
+// `http://rs.tdwg.org/dwc/terms/taxonID`. The first field of the core
+// is used as its ID, the first field of an extension refers to the core ID.
+type Writer interface {
+	// SetCore sets the row type and the fields of the core file.
+	SetCore(rowType string, fields []string) error
+
+	// AddExtension adds an extension with the given row type and fields.
+	// It returns the index of the extension that is used to write its rows.
+	AddExtension(rowType string, fields []string) (int, error)
+
+	// SetEML sets EML data of the archive.
+	SetEML(*eml.EML)
+
+	// WriteCoreRow adds a row to the core file. The row must have the same
+	// number of fields as the core.
+	WriteCoreRow(row []string) error
+
+	// WriteExtensionRow adds a row to the extension file with the given
+	// index. The row must have the same number of fields as the extension.
+	WriteExtensionRow(index int, row []string) error
+
+	// Zip finishes writing, creates meta.xml and eml.xml files and
+	// compresses the archive to a ZIP file with the provided filePath.
+	Zip(filePath string) error
+
+	// TarGz finishes writing, creates meta.xml and eml.xml files and
+	// compresses the archive to a TAR.GZ file with the provided filePath.
+	TarGz(filePath string) error
+
+	// Close cleans up temporary files.
+	Close() error
+}
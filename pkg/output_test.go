@@ -1,12 +1,18 @@
 package dwca_test
 
 import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/gnames/dwca/internal/ent/diagn"
 	dwca "github.com/gnames/dwca/pkg"
 	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/diagn"
 	"github.com/gnames/gnfmt"
 	"github.com/stretchr/testify/assert"
 )
@@ -80,9 +86,11 @@ func TestIndexNoField(t *testing.T) {
 		msg, file string
 		fieldNum  int
 	}{
-		{"idx norm", "tree.tar.gz", 8},
-		{"idx empty", "tree_no_index_info.tar.gz", 8},
-		{"idx empty", "gbif-small.tar.gz", 24},
+		// 6 flat hierarchy fields, higherClassification and
+		// acceptedNameUsageID are added to tree archives.
+		{"idx norm", "tree.tar.gz", 16},
+		{"idx empty", "tree_no_index_info.tar.gz", 16},
+		{"idx empty", "gbif-small.tar.gz", 25},
 	}
 	for _, v := range tests {
 		path := filepath.Join("testdata", "diagn", "hierarchy", v.file)
@@ -133,3 +141,773 @@ func TestAosBirds(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(10, len(ary))
 }
+
+func TestCompositeNames(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "diagn", "scinames", "composite.tar.gz")
+	// the archive misses files of some extensions.
+	cfg := config.New(config.OptExtErrorPolicy("continue"))
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	var extErr *dwca.ErrExtension
+	assert.True(errors.As(err, &extErr))
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(999, len(data))
+
+	var nameIdx int
+	for _, v := range arc.Meta().Core.Fields {
+		if filepath.Base(v.Term) == "scientificNameString" {
+			nameIdx = v.Idx
+		}
+	}
+
+	names := make(map[string]string)
+	for _, v := range data {
+		names[v[0]] = v[nameIdx]
+	}
+
+	tests := []struct {
+		msg, id, name string
+	}{
+		{"species", "57DH4", "Tomomyza nitidula Hesse, 1956"},
+		{"zoo subsp", "BQ74G", "Macrosteles quadripunctatus binotatus (Sahlberg, 1871)"},
+		{"bot subsp", "5J7PB", "Kunzea juniperoides subsp. juniperoides"},
+		{"variety", "9W9ZS", "Brachythecium rivulare var. paradoxum Herzog"},
+		{"form", "B26LR", "Verrucaria nigrescens f. maurioides (Schaer.) Müll. Arg."},
+		{"subgenus", "BF796", "Ctenocardia (Microfragum) Habe, 1951"},
+		{"genus", "88ZH", "Xenostigmina"},
+		{"infrasp", "5F46K", "Silene vulgaris bosniaca (Beck) Janch. ex Greuter, Burdet & Long"},
+	}
+	for _, v := range tests {
+		assert.Equal(v.name, names[v.id], v.msg)
+	}
+}
+
+func TestNormalizeOutputError(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "diagn", "hierarchy", "flat_only.tar.gz")
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	defer arc.Close()
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	// output cannot be saved without its directory.
+	err = os.RemoveAll(cfg.OutputPath)
+	assert.Nil(err)
+
+	done := make(chan error)
+	go func() {
+		done <- arc.Normalize()
+	}()
+	select {
+	case err = <-done:
+		assert.NotNil(err)
+	case <-time.After(30 * time.Second):
+		assert.Fail("Normalize does not return when output fails")
+	}
+}
+
+func TestFlatToTree(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "diagn", "hierarchy", "flat_only.tar.gz")
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	m := arc.Meta()
+	var statusIdx, rankIdx, parentIdx, nameIdx int
+	for _, v := range m.Core.Fields {
+		switch filepath.Base(v.Term) {
+		case "taxonomicStatus":
+			statusIdx = v.Idx
+		case "taxonRank":
+			rankIdx = v.Idx
+		case "parentNameUsageID":
+			parentIdx = v.Idx
+		case "scientificNameString":
+			nameIdx = v.Idx
+		}
+	}
+	assert.Greater(parentIdx, 0)
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Greater(len(data), 999)
+
+	ids := make(map[string][]string)
+	for _, v := range data {
+		ids[v[0]] = v
+	}
+	assert.Equal(len(data), len(ids))
+
+	var kingdoms []string
+	for _, v := range data {
+		if v[statusIdx] != "Accepted" && v[statusIdx] != "accepted" {
+			continue
+		}
+		if v[rankIdx] == "kingdom" {
+			kingdoms = append(kingdoms, v[nameIdx])
+			assert.Equal("", v[parentIdx])
+			continue
+		}
+		parent, ok := ids[v[parentIdx]]
+		assert.True(ok, v[nameIdx])
+		assert.NotEqual(v[0], parent[0])
+	}
+	assert.Contains(kingdoms, "Animalia")
+	assert.Contains(kingdoms, "Fungi")
+
+	// Resseliella theobaldi has genus Resseliella as a parent.
+	row := ids["13267"]
+	parent := ids[row[parentIdx]]
+	assert.Equal("Resseliella", parent[nameIdx])
+	assert.Equal("genus", parent[rankIdx])
+	parent = ids[parent[parentIdx]]
+	assert.Equal("Cecidomyiidae", parent[nameIdx])
+}
+
+func TestTreeToFlat(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "diagn", "hierarchy", "gbif-small.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	idx := make(map[string]int)
+	for _, v := range arc.Meta().Core.Fields {
+		idx[filepath.Base(v.Term)] = v.Idx
+	}
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	rows := make(map[string][]string)
+	for _, v := range data {
+		rows[v[0]] = v
+	}
+
+	tests := []struct {
+		msg, id, genus, family, hier string
+	}{
+		{"subsp", "7262069", "Pan", "Hominidae",
+			"Chordata|Mammalia|Primates|Hominidae|Pan|Pan troglodytes"},
+		{"synonym", "7587055", "Pan", "Hominidae",
+			"Chordata|Mammalia|Primates|Hominidae|Pan"},
+	}
+	for _, v := range tests {
+		row := rows[v.id]
+		assert.Equal(v.genus, row[idx["genus"]], v.msg)
+		assert.Equal(v.family, row[idx["family"]], v.msg)
+		assert.Equal(v.hier, row[idx["higherClassification"]], v.msg)
+	}
+}
+
+func TestSynHierarchy(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "diagn", "synonyms", "hierarchy.tar.gz")
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	idx := make(map[string]int)
+	for _, v := range arc.Meta().Core.Fields {
+		idx[filepath.Base(v.Term)] = v.Idx
+	}
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	rows := make(map[string][]string)
+	for _, v := range data {
+		rows[v[0]] = v
+	}
+
+	tests := []struct {
+		msg, id, acceptedID, parentID string
+	}{
+		{"accepted", "leptogastrinae:tid:2045", "", "leptogastrinae:tid:42"},
+		{"synonym", "leptogastrinae:tid:2044",
+			"leptogastrinae:tid:42", ""},
+		{"child of synonym", "leptogastrinae:tid:1798", "",
+			"leptogastrinae:tid:42"},
+	}
+	for _, v := range tests {
+		row := rows[v.id]
+		assert.Equal(v.acceptedID, row[idx["acceptedNameUsageID"]], v.msg)
+		assert.Equal(v.parentID, row[idx["ParentNameUsageID"]], v.msg)
+	}
+}
+
+func TestSynHierarchyStatusCase(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "diagn", "synonyms", "hierarchy_case.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	assert.Equal(diagn.SynHierarchy, arc.Diagnostics().SynonymType)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	idx := make(map[string]int)
+	for _, v := range arc.Meta().Core.Fields {
+		idx[filepath.Base(v.Term)] = v.Idx
+	}
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	rows := make(map[string][]string)
+	for _, v := range data {
+		rows[v[0]] = v
+	}
+
+	tests := []struct {
+		msg, id, acceptedID, parentID string
+	}{
+		{"Accepted", "2", "", "1"},
+		{"Synonym", "3", "2", "1"},
+		{"Heterotypic SYNONYM", "4", "2", "1"},
+		{"Unaccepted", "5", "", "1"},
+		{"Unknown", "6", "", "1"},
+		{"ACCEPTED child of synonym", "7", "", "2"},
+		{"Invalid", "8", "1", ""},
+	}
+	for _, v := range tests {
+		row := rows[v.id]
+		assert.Equal(v.acceptedID, row[idx["acceptedNameUsageID"]], v.msg)
+		assert.Equal(v.parentID, row[idx["parentNameUsageID"]], v.msg)
+	}
+}
+
+func TestSynExtension(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "diagn", "synonyms", "in_extension.tar.gz")
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	assert.Equal(2, len(arc.Meta().Extensions))
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+	assert.Equal(1, len(arc.Meta().Extensions))
+
+	idx := make(map[string]int)
+	for _, v := range arc.Meta().Core.Fields {
+		idx[filepath.Base(v.Term)] = v.Idx
+	}
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+
+	var syns [][]string
+	for _, v := range data {
+		if v[idx["acceptedNameUsageID"]] != "" {
+			syns = append(syns, v)
+		}
+	}
+	assert.Equal(1, len(syns))
+	syn := syns[0]
+	assert.NotEmpty(syn[0])
+	assert.Equal("leptogastrinae:tid:42", syn[idx["acceptedNameUsageID"]])
+	assert.Equal("synonym", syn[idx["TaxonomicStatus"]])
+	assert.Equal("Bogus synonymous", syn[idx["ScientificName"]])
+	assert.Equal("Bogus synonymous", syn[idx["scientificNameString"]])
+}
+
+func TestSynExtensionDuplicates(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(
+		"testdata", "diagn", "synonyms", "in_extension_dup.tar.gz",
+	)
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	idx := make(map[string]int)
+	for _, v := range arc.Meta().Core.Fields {
+		idx[filepath.Base(v.Term)] = v.Idx
+	}
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+
+	// identical synonym rows get different IDs.
+	ids := make(map[string]struct{})
+	var synsNum int
+	for _, v := range data {
+		if v[idx["acceptedNameUsageID"]] != "" {
+			synsNum++
+			ids[v[0]] = struct{}{}
+		}
+	}
+	assert.Equal(2, synsNum)
+	assert.Equal(2, len(ids))
+}
+
+func TestCanonicalNamesMixed(t *testing.T) {
+	assert := assert.New(t)
+	// most names are canonical, a few rows have full names or names
+	// composed from their parts.
+	path := filepath.Join("testdata", "diagn", "scinames", "mixed.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+	assert.Equal(diagn.SciNameCanonical, arc.Diagnostics().SciNameType)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	recs, err := arc.CoreRecordSlice(0, 0)
+	assert.Nil(err)
+	names := make(map[string]string)
+	for _, v := range recs {
+		names[v.Get("taxonID")] = v.Get("scientificNameString")
+	}
+
+	tests := []struct {
+		msg, id, name string
+	}{
+		{"canonical", "id0", "Aus busa L."},
+		{"full", "full", "Aus cus L."},
+		{"composite", "composite", "Aus dus Mill."},
+	}
+	for _, v := range tests {
+		assert.Equal(v.name, names[v.id], v.msg)
+	}
+}
+
+func TestMissingExtensionFiles(t *testing.T) {
+	assert := assert.New(t)
+	// the archive misses files of SpeciesProfile and Distribution
+	// extensions.
+	path := filepath.Join("testdata", "diagn", "scinames", "composite.tar.gz")
+
+	// missing files stop normalization by default.
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	assert.Equal(3, len(arc.Meta().Extensions))
+	err = arc.Normalize()
+	var extErr *dwca.ErrExtension
+	assert.True(errors.As(err, &extErr))
+	assert.Contains(extErr.RowType, "SpeciesProfile")
+
+	// with "continue" policy all missing extensions are reported.
+	cfg = config.New(config.OptExtErrorPolicy("continue"))
+	arc, err = dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	err = arc.Normalize()
+	assert.True(errors.As(err, &extErr))
+	joined, ok := err.(interface{ Unwrap() []error })
+	assert.True(ok)
+	assert.Equal(2, len(joined.Unwrap()))
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+	exts := arc.Meta().Extensions
+	assert.Equal(1, len(exts))
+	assert.Equal("VernacularName.txt", exts[0].Files.Location)
+}
+
+func TestExtErrors(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		msg, policy string
+		extsNum     int
+	}{
+		{"abort", "abort", 0},
+		{"continue", "continue", 1},
+	}
+
+	for _, v := range tests {
+		path := filepath.Join("testdata", "ext_err.tar.gz")
+		cfg := config.New(config.OptExtErrorPolicy(v.policy))
+		arc, err := dwca.Factory(path, cfg)
+		assert.Nil(err, v.msg)
+
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err, v.msg)
+
+		err = arc.Normalize()
+		assert.NotNil(err, v.msg)
+		var extErr *dwca.ErrExtension
+		assert.True(errors.As(err, &extErr), v.msg)
+		assert.Equal(1, extErr.Index, v.msg)
+		assert.Equal("distribution.txt", extErr.File, v.msg)
+
+		if v.extsNum == 0 {
+			continue
+		}
+
+		arc, err = dwca.FactoryOutput(cfg)
+		assert.Nil(err, v.msg)
+		err = arc.Load(cfg.OutputPath)
+		assert.Nil(err, v.msg)
+		exts := arc.Meta().Extensions
+		assert.Equal(v.extsNum, len(exts), v.msg)
+		assert.Equal("vernacular.txt", exts[0].Files.Location, v.msg)
+
+		data, err := arc.ExtensionSlice(0, 0, 0)
+		assert.Nil(err, v.msg)
+		assert.Equal(1, len(data), v.msg)
+
+		// the file of the failed extension is removed from the output.
+		_, err = os.Stat(filepath.Join(cfg.OutputPath, "distribution.txt"))
+		assert.True(os.IsNotExist(err), v.msg)
+	}
+}
+
+func TestOccurrence(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "occurrence.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	// no higher taxa are generated from flat hierarchy of occurrences.
+	fields := arc.Meta().Core.Fields
+	assert.Equal("scientificNameString", filepath.Base(fields[len(fields)-1].Term))
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(7, len(data))
+	rows := make(map[string][]string)
+	for _, v := range data {
+		rows[v[0]] = v
+	}
+
+	tests := []struct {
+		msg, id, bor, date, lat, lon string
+	}{
+		{"date", "occ1", "PreservedSpecimen", "2020-01-05", "45.123457", "-75.1"},
+		// invalid coordinates are kept as is.
+		{"text date", "occ2", "HumanObservation", "2020-01-05", "95", "10"},
+		{"range", "occ3", "HumanObservation", "2020-01-05/2020-01-10", "45,5", "181"},
+		{"parts", "occ4", "FossilSpecimen", "1999-07", "abc", "10"},
+		{"dmy", "occ5", "Strange", "2019-12-25", "0", "12.3"},
+		{"month", "occ6", "MachineObservation", "2018-03", "", ""},
+		{"verbatim", "occ7", "LivingSpecimen", "spring 2018", "", ""},
+	}
+	for _, v := range tests {
+		row := rows[v.id]
+		assert.Equal(v.bor, row[1], v.msg)
+		assert.Equal(v.date, row[2], v.msg)
+		assert.Equal(v.lat, row[6], v.msg)
+		assert.Equal(v.lon, row[7], v.msg)
+		assert.Equal(row[8], row[15], v.msg)
+	}
+}
+
+func TestOccurrenceDates(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	path := filepath.Join("testdata", "occurrence_dates.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	// rows with invalid coordinates are reported once.
+	msg := "Coordinates are not valid decimal degrees"
+	assert.Equal(1, strings.Count(buf.String(), msg))
+	assert.Contains(buf.String(), "rows=3")
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	rows := make(map[string][]string)
+	for _, v := range data {
+		rows[v[0]] = v
+	}
+
+	tests := []struct {
+		msg, id, date, lat, lon string
+	}{
+		{"feb 30", "d1", "2023-02", "45.123457", "-75"},
+		{"apr 31", "d2", "2023-04", "-90", "180"},
+		{"day 0", "d3", "2023-04", "90.0000001", "0"},
+		{"feb 29", "d4", "2020-02-29", "NaN", "10"},
+		{"feb 29 non-leap", "d5", "2023-02", "10", ""},
+		{"no month", "d6", "1999", "", ""},
+		{"bad year", "d7", "", "12.5", "3"},
+		{"bad month", "d8", "2023", "0", "0"},
+		{"bad date", "d9", "2023-02-30", "", ""},
+		{"mdy", "d10", "2019-12-25", "", ""},
+		{"ambiguous", "d11", "05/06/2019", "", ""},
+		{"zone", "d12", "2020-01-05T10:30:00+02:00", "", ""},
+		{"time", "d13", "2020-01-05T10:30:00", "", ""},
+		{"text", "d14", "2020-01-05", "", ""},
+		{"text range", "d15", "2020-01-05/2020-02-10", "", ""},
+		{"year", "d16", "2020", "", ""},
+	}
+	for _, v := range tests {
+		row := rows[v.id]
+		assert.Equal(v.date, row[1], v.msg)
+		assert.Equal(v.lat, row[5], v.msg)
+		assert.Equal(v.lon, row[6], v.msg)
+	}
+}
+
+func TestEvent(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "event.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	data, err := arc.CoreSlice(0, 0)
+	assert.Nil(err)
+	assert.Equal(5, len(data))
+	rows := make(map[string][]string)
+	for _, v := range data {
+		rows[v[0]] = v
+	}
+
+	tests := []struct {
+		msg, id, date, country, locality, lat, lon string
+	}{
+		{"root", "ev1", "2020-06", "Canada", "Lake Ontario shore", "43.5", "-79.2"},
+		{"child", "ev2", "2020-06-15", "Canada", "Lake Ontario shore", "43.5", "-79.2"},
+		{"grandchild", "ev3", "2020-06-15", "Canada", "Station 3", "43.512346", "-79.25"},
+		{"cycle", "ev4", "2021", "", "", "", ""},
+	}
+	for _, v := range tests {
+		row := rows[v.id]
+		assert.Equal(v.date, row[3], v.msg)
+		assert.Equal(v.country, row[4], v.msg)
+		assert.Equal(v.locality, row[5], v.msg)
+		assert.Equal(v.lat, row[6], v.msg)
+		assert.Equal(v.lon, row[7], v.msg)
+	}
+
+	// inherited fields that are missing in the extension are added to it.
+	idx := make(map[string]int)
+	for _, v := range arc.Meta().Extensions[0].Fields {
+		idx[filepath.Base(v.Term)] = v.Idx
+	}
+	for _, v := range []string{"country", "locality", "decimalLatitude"} {
+		assert.Contains(idx, v)
+	}
+
+	data, err = arc.ExtensionSlice(0, 0, 0)
+	assert.Nil(err)
+	assert.Equal(4, len(data))
+	rows = make(map[string][]string)
+	for _, v := range data {
+		rows[v[1]] = v
+	}
+
+	occTests := []struct {
+		msg, id, bor, date, country, locality, lat string
+	}{
+		{"inherit", "occ1", "HumanObservation", "2020-06-15", "Canada",
+			"Lake Ontario shore", "43.5"},
+		{"own date", "occ2", "PreservedSpecimen", "2020-06-16", "Canada",
+			"Station 3", "43.512346"},
+		{"root event", "occ3", "", "2020-06", "Canada",
+			"Lake Ontario shore", "43.5"},
+		{"cycle", "occ4", "", "2021", "", "", ""},
+	}
+	for _, v := range occTests {
+		row := rows[v.id]
+		assert.Equal(v.bor, row[idx["basisOfRecord"]], v.msg)
+		assert.Equal(v.date, row[idx["eventDate"]], v.msg)
+		assert.Equal(v.locality, row[idx["locality"]], v.msg)
+		assert.Equal(v.lat, row[idx["decimalLatitude"]], v.msg)
+		assert.Equal(v.country, row[idx["country"]], v.msg)
+	}
+}
+
+func TestEventGroups(t *testing.T) {
+	assert := assert.New(t)
+	// ev2 has its own date and latitude, but no longitude, ev3 has
+	// neither dates nor coordinates.
+	path := filepath.Join("testdata", "event_groups.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+	err = arc.Normalize()
+	assert.Nil(err)
+
+	arc, err = dwca.FactoryOutput(cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.OutputPath)
+	assert.Nil(err)
+
+	recs, err := arc.CoreRecordSlice(0, 0)
+	assert.Nil(err)
+	evs := make(map[string]dwca.Record)
+	for _, v := range recs {
+		evs[v.Get("eventID")] = v
+	}
+
+	// groups of fields are inherited only if all their fields are empty.
+	tests := []struct {
+		msg, id, date, year, lat, lon, datum string
+	}{
+		{"root", "ev1", "2020-06-15", "2020", "43.5", "-79.2", "WGS84"},
+		{"own groups", "ev2", "2021-07-01", "", "44", "", ""},
+		{"inherited groups", "ev3", "2021-07-01", "", "44", "", ""},
+	}
+	for _, v := range tests {
+		rec := evs[v.id]
+		assert.Equal(v.date, rec.Get("eventDate"), v.msg)
+		assert.Equal(v.year, rec.Get("year"), v.msg)
+		assert.Equal(v.lat, rec.Get("decimalLatitude"), v.msg)
+		assert.Equal(v.lon, rec.Get("decimalLongitude"), v.msg)
+		assert.Equal(v.datum, rec.Get("geodeticDatum"), v.msg)
+	}
+
+	recs, err = arc.ExtensionRecordSlice("http://rs.tdwg.org/dwc/terms/Occurrence", 0, 0)
+	assert.Nil(err)
+	occs := make(map[string]dwca.Record)
+	for _, v := range recs {
+		occs[v.Get("occurrenceID")] = v
+	}
+
+	occTests := []struct {
+		msg, id, date, year, lat, datum string
+	}{
+		{"inherit", "occ1", "2020-06-15", "2020", "43.5", "WGS84"},
+		{"own month", "occ2", "", "", "", ""},
+	}
+	for _, v := range occTests {
+		rec := occs[v.id]
+		assert.Equal(v.date, rec.Get("eventDate"), v.msg)
+		assert.Equal(v.year, rec.Get("year"), v.msg)
+		assert.Equal(v.lat, rec.Get("decimalLatitude"), v.msg)
+		assert.Equal(v.datum, rec.Get("geodeticDatum"), v.msg)
+	}
+}
@@ -0,0 +1,281 @@
+package dwca
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gnames/dwca/pkg/ent/meta"
+	"golang.org/x/sync/errgroup"
+)
+
+// Record is a row of a Core or an Extension file that gives access to its
+// values by terms. Terms can be given as short names, like `taxonID`, or
+// as full URIs, like `http://rs.tdwg.org/dwc/terms/taxonID`, the case
+// does not matter.
+type Record struct {
+	// RowNum is the number of the row among data rows of the Core or the
+	// Extension, counted from 1. Rows of several data files are counted
+	// together, header lines and skipped bad rows are not counted. It is
+	// not a line number, because fields might contain line breaks.
+	RowNum int
+
+	// Row contains values of the record in the order of columns.
+	Row []string
+
+	fields *recordFields
+}
+
+// recordFields maps terms of a data file to indices of its columns. It is
+// shared by all records of the file.
+type recordFields struct {
+	// idx maps lowercased short terms and full URIs to column indices.
+	idx map[string]int
+
+	// terms are full terms of the columns in the order of their indices.
+	terms []string
+}
+
+// Get returns the value of the field with the given term. It returns an
+// empty string if there is no such field.
+func (r Record) Get(term string) string {
+	idx, ok := r.index(term)
+	if !ok {
+		return ""
+	}
+	return r.Row[idx]
+}
+
+// Has checks if the record has a field with the given term.
+func (r Record) Has(term string) bool {
+	_, ok := r.index(term)
+	return ok
+}
+
+// Terms returns full terms of the record fields in the order of columns.
+func (r Record) Terms() []string {
+	if r.fields == nil {
+		return nil
+	}
+	return slices.Clone(r.fields.terms)
+}
+
+func (r Record) index(term string) (int, bool) {
+	if r.fields == nil {
+		return 0, false
+	}
+	idx, ok := r.fields.idx[strings.ToLower(term)]
+	if !ok {
+		idx, ok = r.fields.idx[strings.ToLower(path.Base(term))]
+	}
+	if !ok || idx >= len(r.Row) {
+		return 0, false
+	}
+	return idx, true
+}
+
+// newRecordFields creates a term index for fields of a data file. The ID
+// field is available as `id` for the Core and as `coreid` for
+// Extensions, unless there are fields with such names.
+func newRecordFields(
+	fields []meta.Field,
+	idTerm string,
+	idIdx int,
+) *recordFields {
+	res := &recordFields{idx: make(map[string]int)}
+	for _, v := range fields {
+		if v.Idx < 0 {
+			continue
+		}
+		res.setTerm(v.Idx, v.Term)
+		term := strings.ToLower(v.Term)
+		res.idx[term] = v.Idx
+		res.idx[path.Base(term)] = v.Idx
+	}
+
+	if _, ok := res.idx[idTerm]; !ok && idIdx >= 0 {
+		res.idx[idTerm] = idIdx
+		if idIdx >= len(res.terms) || res.terms[idIdx] == "" {
+			res.setTerm(idIdx, idTerm)
+		}
+	}
+	return res
+}
+
+func (rf *recordFields) setTerm(idx int, term string) {
+	if idx >= len(rf.terms) {
+		rf.terms = append(rf.terms, make([]string, idx-len(rf.terms)+1)...)
+	}
+	rf.terms[idx] = term
+}
+
+func (a *arch) coreRecordFields() *recordFields {
+	return newRecordFields(a.meta.Core.Fields, "id", a.meta.Core.ID.Idx)
+}
+
+func (a *arch) extRecordFields(index int) *recordFields {
+	ext := a.meta.Extensions[index]
+	return newRecordFields(ext.Fields, "coreid", ext.CoreID.Idx)
+}
+
+// ExtensionIndex returns the index of the extension with the given row
+// type. The row type can be given as a full URI, or as its last element,
+// for example `VernacularName`.
+func (a *arch) ExtensionIndex(rowType string) (int, error) {
+	for i, v := range a.meta.Extensions {
+		if matchRowType(v.RowType, rowType) {
+			return i, nil
+		}
+	}
+	return -1, &ErrUnknownRowType{RowType: rowType}
+}
+
+// matchRowType checks if a row type corresponds to a name that is either
+// a full URI or its last element.
+func matchRowType(rowType, name string) bool {
+	rowType = strings.ToLower(rowType)
+	name = strings.ToLower(name)
+	return rowType == name || path.Base(rowType) == name
+}
+
+// CoreRecords takes a channel and populates it with Core records. The
+// channel is closed when the data is exhausted. It returns the number
+// of records.
+func (a *arch) CoreRecords(
+	ctx context.Context,
+	ch chan<- Record,
+) (int, error) {
+	fields := a.coreRecordFields()
+	stream := func(ctx context.Context, chRow chan []string) (int, error) {
+		return a.CoreStream(ctx, chRow)
+	}
+	return streamRecords(ctx, fields, ch, stream)
+}
+
+// CoreRecordSlice returns Core records according to the offset and
+// limit, the same way as CoreSlice.
+func (a *arch) CoreRecordSlice(offset, limit int) ([]Record, error) {
+	rows, err := a.CoreSlice(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return newRecords(a.coreRecordFields(), rows, offset), nil
+}
+
+// ExtensionRecords takes a row type of an extension and a channel, and
+// populates the channel with the extension records. The channel is closed
+// when the data is exhausted. It returns the number of records.
+func (a *arch) ExtensionRecords(
+	ctx context.Context,
+	rowType string,
+	ch chan<- Record,
+) (int, error) {
+	index, err := a.ExtensionIndex(rowType)
+	if err != nil {
+		close(ch)
+		return 0, err
+	}
+	fields := a.extRecordFields(index)
+	stream := func(ctx context.Context, chRow chan []string) (int, error) {
+		return a.ExtensionStream(ctx, index, chRow)
+	}
+	return streamRecords(ctx, fields, ch, stream)
+}
+
+// ExtensionRecordSlice returns records of the extension with the given
+// row type according to the offset and limit, the same way as
+// ExtensionSlice.
+func (a *arch) ExtensionRecordSlice(
+	rowType string,
+	offset, limit int,
+) ([]Record, error) {
+	index, err := a.ExtensionIndex(rowType)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := a.ExtensionSlice(index, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return newRecords(a.extRecordFields(index), rows, offset), nil
+}
+
+func newRecords(fields *recordFields, rows [][]string, offset int) []Record {
+	res := make([]Record, len(rows))
+	for i, v := range rows {
+		res[i] = Record{RowNum: offset + i + 1, Row: v, fields: fields}
+	}
+	return res
+}
+
+// streamRecords converts rows of a data stream to records.
+func streamRecords(
+	ctx context.Context,
+	fields *recordFields,
+	ch chan<- Record,
+	stream func(context.Context, chan []string) (int, error),
+) (int, error) {
+	defer close(ch)
+	chRow := make(chan []string)
+	g, ctx := errgroup.WithContext(ctx)
+
+	var count int
+	g.Go(func() error {
+		var err error
+		count, err = stream(ctx, chRow)
+		return err
+	})
+
+	g.Go(func() error {
+		var num int
+		for row := range chRow {
+			num++
+			select {
+			case <-ctx.Done():
+				for range chRow {
+				}
+				return ctx.Err()
+			case ch <- Record{RowNum: num, Row: row, fields: fields}:
+			}
+		}
+		return nil
+	})
+
+	err := g.Wait()
+	return count, err
+}
+
+// CoreByID returns the Core record with the given ID. If there are several
+// records with the same ID, the first one is returned. Records are found
+// using an index of data files, that is built on the first call, unless
+// it was built during Load.
+func (a *arch) CoreByID(id string) (Record, error) {
+	rows, err := a.dcFile.CoreByID(a.root, a.meta, id)
+	if err != nil {
+		return Record{}, err
+	}
+	if len(rows) == 0 {
+		return Record{}, &ErrRecordNotFound{ID: id}
+	}
+	row := rows[0]
+	return Record{RowNum: row.RowNum, Row: row.Values, fields: a.coreRecordFields()}, nil
+}
+
+// ExtensionsByCoreID returns records of all extensions that refer to the
+// Core record with the given ID. Extensions are in the same order as in
+// meta.xml.
+func (a *arch) ExtensionsByCoreID(id string) ([][]Record, error) {
+	res := make([][]Record, len(a.meta.Extensions))
+	for i := range a.meta.Extensions {
+		rows, err := a.dcFile.ExtensionByCoreID(i, a.root, a.meta, id)
+		if err != nil {
+			return nil, err
+		}
+		fields := a.extRecordFields(i)
+		for _, v := range rows {
+			res[i] = append(res[i], Record{RowNum: v.RowNum, Row: v.Values, fields: fields})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package dwca_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/gnfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecords(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(filepath.Join("testdata", "data.tar.gz"), cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	recs, err := arc.CoreRecordSlice(1, 2)
+	assert.Nil(err)
+	assert.Equal(2, len(recs))
+	rec := recs[0]
+	assert.Equal(2, rec.RowNum)
+
+	tests := []struct {
+		msg, term, val string
+		has            bool
+	}{
+		{"short", "scientificName", "Leptogastrinae", true},
+		{"lower", "scientificname", "Leptogastrinae", true},
+		{"full", "http://rs.tdwg.org/dwc/terms/TaxonRank", "subfamily", true},
+		{"other ns", "http://purl.org/dc/terms/source",
+			"http://leptogastrinae.lifedesks.org/pages/42", true},
+		{"id", "id", "leptogastrinae:tid:42", true},
+		{"absent", "vernacularName", "", false},
+	}
+	for _, v := range tests {
+		assert.Equal(v.has, rec.Has(v.term), v.msg)
+		assert.Equal(v.val, rec.Get(v.term), v.msg)
+	}
+	terms := rec.Terms()
+	assert.Equal("id", terms[0])
+	assert.Equal("http://rs.tdwg.org/dwc/terms/ScientificName", terms[2])
+
+	ch := make(chan dwca.Record)
+	var nums []int
+	var names []string
+	done := make(chan struct{})
+	go func() {
+		for r := range ch {
+			nums = append(nums, r.RowNum)
+			names = append(names, r.Get("ScientificName"))
+		}
+		close(done)
+	}()
+	count, err := arc.CoreRecords(context.Background(), ch)
+	<-done
+	assert.Nil(err)
+	assert.Equal(587, count)
+	assert.Equal(587, len(nums))
+	assert.Equal(587, nums[586])
+	assert.Equal("Leptogastrinae", names[1])
+
+	chExt := make(chan dwca.Record)
+	var exts []dwca.Record
+	done = make(chan struct{})
+	go func() {
+		for r := range chExt {
+			exts = append(exts, r)
+		}
+		close(done)
+	}()
+	count, err = arc.ExtensionRecords(
+		context.Background(), "VernacularName", chExt,
+	)
+	<-done
+	assert.Nil(err)
+	assert.Equal(1, count)
+	assert.Equal("Grass flies", exts[0].Get("vernacularName"))
+	assert.Equal("en", exts[0].Get("http://rs.gbif.org/thesaurus/languageCode"))
+	assert.Equal("leptogastrinae:tid:42", exts[0].Get("coreid"))
+	assert.Equal(1, exts[0].RowNum)
+
+	recs, err = arc.ExtensionRecordSlice(
+		"http://rs.gbif.org/ipt/terms/1.0/VernacularName", 0, 0,
+	)
+	assert.Nil(err)
+	assert.Equal(1, len(recs))
+
+	_, err = arc.ExtensionRecordSlice("Distribution", 0, 0)
+	var rtErr *dwca.ErrUnknownRowType
+	assert.True(errors.As(err, &rtErr))
+
+	err = arc.Close()
+	assert.Nil(err)
+}
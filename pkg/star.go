@@ -0,0 +1,171 @@
+package dwca
+
+import (
+	"context"
+
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"golang.org/x/sync/errgroup"
+)
+
+// StarRecord is a Core record together with records of all extensions that
+// refer to it.
+type StarRecord struct {
+	// Core is the record of the Core.
+	Core Record
+
+	// Extensions contains records of every extension that refer to the
+	// Core record. Extensions are in the same order as in meta.xml.
+	Extensions [][]Record
+
+	rowTypes []string
+}
+
+// Extension returns records of the extension with the given row type.
+// The row type can be a full URI or its last element, for example
+// `VernacularName`.
+func (s StarRecord) Extension(rowType string) []Record {
+	for i, v := range s.rowTypes {
+		if matchRowType(v, rowType) {
+			return s.Extensions[i]
+		}
+	}
+	return nil
+}
+
+// StarRecords takes a channel and populates it with Core records joined
+// with records of all extensions that refer to them by coreid. Records
+// come sorted by their IDs. Extension records without a Core record are
+// ignored. Data files are sorted using temporary files, so archives can
+// be larger than memory. The channel is closed when the data is exhausted.
+// It returns the number of Core records.
+func (a *arch) StarRecords(
+	ctx context.Context,
+	ch chan<- StarRecord,
+) (int, error) {
+	defer close(ch)
+	g, ctx := errgroup.WithContext(ctx)
+
+	chCore := make(chan dcfile.Row)
+	g.Go(func() error {
+		_, err := a.dcFile.SortedCoreStream(ctx, a.root, a.meta, chCore)
+		return err
+	})
+
+	exts := make([]*extCursor, len(a.meta.Extensions))
+	rowTypes := make([]string, len(a.meta.Extensions))
+	for i, v := range a.meta.Extensions {
+		chExt := make(chan dcfile.Row)
+		exts[i] = &extCursor{
+			ch:     chExt,
+			fields: a.extRecordFields(i),
+			keyIdx: v.CoreID.Idx,
+		}
+		rowTypes[i] = v.RowType
+		g.Go(func() error {
+			_, err := a.dcFile.SortedExtensionStream(ctx, i, a.root, a.meta, chExt)
+			return err
+		})
+	}
+
+	var count int
+	g.Go(func() error {
+		// extension streams must be exhausted, so their goroutines can
+		// finish.
+		defer func() {
+			for range chCore {
+			}
+			for _, v := range exts {
+				for range v.ch {
+				}
+			}
+		}()
+
+		fields := a.coreRecordFields()
+		idIdx := a.meta.Core.ID.Idx
+		for row := range chCore {
+			id := rowKey(row.Values, idIdx)
+			res := StarRecord{
+				Core:       Record{RowNum: row.RowNum, Row: row.Values, fields: fields},
+				Extensions: make([][]Record, len(exts)),
+				rowTypes:   rowTypes,
+			}
+			for i, v := range exts {
+				res.Extensions[i] = v.records(id)
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case ch <- res:
+				count++
+			}
+		}
+		return nil
+	})
+
+	err := g.Wait()
+	return count, err
+}
+
+// extCursor reads rows of an extension sorted by coreid, and groups them
+// by coreid.
+type extCursor struct {
+	ch     <-chan dcfile.Row
+	fields *recordFields
+	keyIdx int
+
+	// row is a row that was read, but does not belong to the last group.
+	row *dcfile.Row
+
+	// key is the coreid of the last group, and group contains its records.
+	// The group is kept for Core records with duplicate IDs.
+	key      string
+	group    []Record
+	hasGroup bool
+}
+
+// records returns extension records with the given coreid. Keys must come
+// in ascending order.
+func (c *extCursor) records(key string) []Record {
+	if c.hasGroup && c.key == key {
+		return c.group
+	}
+	c.key = key
+	c.group = nil
+	c.hasGroup = true
+
+	for {
+		if c.row == nil {
+			row, ok := <-c.ch
+			if !ok {
+				return c.group
+			}
+			c.row = &row
+		}
+
+		k := rowKey(c.row.Values, c.keyIdx)
+		switch {
+		case k < key:
+			// extension record without a Core record.
+			c.row = nil
+		case k > key:
+			return c.group
+		default:
+			c.group = append(c.group, Record{
+				RowNum: c.row.RowNum,
+				Row:    c.row.Values,
+				fields: c.fields,
+			})
+			c.row = nil
+		}
+	}
+}
+
+// rowKey returns the value of the field with the given index, or an empty
+// string if there is no such field.
+func rowKey(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return row[idx]
+}
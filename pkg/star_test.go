@@ -0,0 +1,57 @@
+package dwca_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/gnfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStarRecords(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(filepath.Join("testdata", "data.tar.gz"), cfg)
+	assert.Nil(err)
+	defer arc.Close()
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	ch := make(chan dwca.StarRecord)
+	var recs []dwca.StarRecord
+	done := make(chan struct{})
+	go func() {
+		for r := range ch {
+			recs = append(recs, r)
+		}
+		close(done)
+	}()
+	count, err := arc.StarRecords(context.Background(), ch)
+	<-done
+	assert.Nil(err)
+	assert.Equal(587, count)
+	assert.Equal(587, len(recs))
+
+	var found bool
+	for i, v := range recs {
+		if i > 0 {
+			assert.LessOrEqual(recs[i-1].Core.Get("id"), v.Core.Get("id"))
+		}
+		assert.Equal(1, len(v.Extensions))
+		if v.Core.Get("id") != "leptogastrinae:tid:42" {
+			assert.Empty(v.Extension("VernacularName"))
+			continue
+		}
+		found = true
+		assert.Equal("Leptogastrinae", v.Core.Get("scientificName"))
+		vern := v.Extension("VernacularName")
+		assert.Equal(1, len(vern))
+		assert.Equal("Grass flies", vern[0].Get("vernacularName"))
+		assert.Equal("leptogastrinae:tid:42", vern[0].Get("coreid"))
+		assert.Nil(v.Extension("Distribution"))
+	}
+	assert.True(found)
+}
@@ -0,0 +1,83 @@
+package dwca
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gnames/dwca/pkg/ent/diagn"
+	"github.com/gnames/dwca/pkg/ent/tree"
+	"golang.org/x/sync/errgroup"
+)
+
+// Tree creates a taxonomic tree from Core records, using taxonID,
+// parentNameUsageID (or higherTaxonID) and acceptedNameUsageID fields.
+// Synonyms that point to their accepted taxa via the parent field are
+// recognized by their taxonomicStatus. The tree is created once and
+// reused by following calls.
+func (a *arch) Tree(ctx context.Context) (*tree.Tree, error) {
+	if a.meta == nil || a.meta.Core == nil {
+		return nil, errors.New("archive is not loaded")
+	}
+	if a.tree != nil {
+		return a.tree, nil
+	}
+	if a.taxon == nil {
+		a.taxon = a.newTaxon()
+	}
+
+	var nodes []tree.Node
+	ch := make(chan []string)
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		_, err := a.CoreStream(ctx, ch)
+		return err
+	})
+	g.Go(func() error {
+		var num int
+		for row := range ch {
+			num++
+			nodes = append(nodes, a.treeNode(row, num))
+		}
+		return nil
+	})
+
+	err := g.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	a.tree = tree.New(nodes)
+	return a.tree, nil
+}
+
+// treeNode creates a node of the taxonomic tree from a Core row.
+func (a *arch) treeNode(row []string, num int) tree.Node {
+	res := tree.Node{
+		ID:       a.rowID(row),
+		ParentID: a.parentID(row),
+		RowNum:   num,
+	}
+
+	if a.dgn != nil && a.dgn.SciNameType == diagn.SciNameComposite {
+		res.Name, _ = a.taxon.genCompositeName(row)
+	} else {
+		res.Name, _ = a.taxon.genNameAu(row)
+	}
+
+	rank := a.taxon.field(row, a.taxon.taxonRank)
+	if rank == "" {
+		rank = a.taxon.field(row, a.taxon.scientificNameRank)
+	}
+	res.Rank = strings.ToLower(rank)
+
+	if idx := a.taxon.acceptedNameUsageID; idx != -1 {
+		res.AcceptedID = a.taxon.field(row, idx)
+		if _, ok := nullIDs[res.AcceptedID]; ok {
+			res.AcceptedID = ""
+		}
+	} else if a.isSynHierarchy() && a.isSynonym(row) {
+		res.AcceptedID = res.ParentID
+	}
+	return res
+}
@@ -0,0 +1,96 @@
+package dwca_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/gnfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTree(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.New(config.OptWrongFieldsNum(gnfmt.ProcessBadRow))
+	arc, err := dwca.Factory(filepath.Join("testdata", "data.tar.gz"), cfg)
+	assert.Nil(err)
+	defer arc.Close()
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	tr, err := arc.Tree(context.Background())
+	assert.Nil(err)
+	tr2, err := arc.Tree(context.Background())
+	assert.Nil(err)
+	assert.Same(tr, tr2)
+
+	id := "leptogastrinae:tid:42"
+	node, ok := tr.Node(id)
+	assert.True(ok)
+	assert.Equal("Leptogastrinae", node.Name)
+	assert.Equal("subfamily", node.Rank)
+	assert.Equal(2, node.RowNum)
+
+	roots := tr.Roots()
+	assert.Greater(len(roots), 0)
+	assert.Equal(id, roots[0].ID)
+
+	children := tr.Children(id)
+	var names []string
+	for _, v := range children {
+		names = append(names, v.Name)
+	}
+	assert.Contains(names, "Leptogastrini")
+	assert.Contains(names, "Acronychini")
+
+	syns := tr.Synonyms(id)
+	assert.Equal(1, len(syns))
+	assert.Equal("Leptogastridae", syns[0].Name)
+	assert.True(syns[0].IsSynonym())
+
+	desc := tr.Descendants(id)
+	assert.Greater(len(desc), len(children))
+	last := desc[len(desc)-1]
+	anc := tr.Ancestors(last.ID)
+	assert.Equal(id, anc[len(anc)-1].ID)
+	assert.Equal(len(anc), tr.Depth(last.ID))
+
+	ranks := tr.RankCounts(id)
+	assert.Equal(2, ranks["tribe"])
+	assert.Greater(ranks["species"], 0)
+	var total int
+	for _, v := range tr.DepthCounts() {
+		total += v
+	}
+	assert.Greater(total, len(desc))
+}
+
+func TestTreeNullIDs(t *testing.T) {
+	assert := assert.New(t)
+	// null markers are used instead of empty IDs.
+	path := filepath.Join("testdata", "tree_null_ids.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	defer arc.Close()
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	tr, err := arc.Tree(context.Background())
+	assert.Nil(err)
+
+	roots := tr.Roots()
+	assert.Equal(1, len(roots))
+	assert.Equal("1", roots[0].ID)
+	assert.False(roots[0].IsSynonym())
+
+	children := tr.Children("1")
+	assert.Equal(1, len(children))
+	assert.Equal("Pinus strobus", children[0].Name)
+
+	syns := tr.Synonyms("2")
+	assert.Equal(1, len(syns))
+	assert.Equal("Strobus strobus", syns[0].Name)
+}
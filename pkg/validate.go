@@ -0,0 +1,255 @@
+package dwca
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+
+	"github.com/gnames/dwca/pkg/ent/diagn"
+	"github.com/gnames/dwca/pkg/ent/meta"
+	"github.com/gnames/dwca/pkg/ent/valid"
+	"golang.org/x/sync/errgroup"
+)
+
+// validRec keeps data of a Core record needed to check references
+// between records.
+type validRec struct {
+	row                  int
+	id, parent, accepted string
+}
+
+// Validate checks the archive for problems and returns a report about
+// them. The error is returned only if validation could not be finished.
+func (a *arch) Validate(ctx context.Context) (*valid.Report, error) {
+	if a.meta == nil || a.meta.Core == nil {
+		return nil, errors.New("archive is not loaded")
+	}
+	if a.taxon == nil {
+		a.taxon = a.newTaxon()
+	}
+
+	res := valid.New()
+	fsys, dir, err := a.dcFile.FS(a.root)
+	if err != nil {
+		return nil, err
+	}
+
+	// ids stay nil if the Core cannot be read.
+	var ids map[string]int
+	core := a.meta.Core
+	if a.validFile(res, fsys, dir, core.Files, core.RowType) {
+		ids, err = a.validateCore(ctx, res)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, ext := range a.meta.Extensions {
+		if !a.validFile(res, fsys, dir, ext.Files, ext.RowType) {
+			continue
+		}
+		err = a.validateExtension(ctx, res, i, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// validFile checks if data files exist and adds them to the report.
+func (a *arch) validFile(
+	res *valid.Report,
+	fsys fs.FS,
+	dir string,
+	files meta.Files,
+	rowType string,
+) bool {
+	file := files.Location
+	res.Files = append(res.Files, valid.File{Name: file, RowType: rowType})
+	paths := files.Paths()
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	ok := true
+	for _, v := range paths {
+		_, err := fs.Stat(fsys, path.Join(dir, v))
+		if err != nil {
+			res.AddMessage(valid.MissingFile, v, err.Error())
+			ok = false
+		}
+	}
+	return ok
+}
+
+// validateCore checks rows of the Core and references between Core
+// records. It returns IDs of the Core records with their row numbers,
+// or nil if the Core cannot be read.
+func (a *arch) validateCore(
+	ctx context.Context,
+	res *valid.Report,
+) (map[string]int, error) {
+	core := a.meta.Core
+	file := core.Files.Location
+	maxIdx := core.ID.Idx
+	for _, v := range core.Fields {
+		maxIdx = max(maxIdx, v.Idx)
+	}
+	checkName := a.taxon.scientificName != -1 ||
+		a.dgn.SciNameType == diagn.SciNameComposite
+
+	ids := make(map[string]int)
+	var recs []validRec
+
+	ch := make(chan []string)
+	g, gCtx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var rowNum int
+		for row := range ch {
+			rowNum++
+			if maxIdx >= len(row) {
+				res.Add(valid.FieldIndex, file, rowNum)
+			}
+			if checkName {
+				if name, _ := a.taxon.genCompositeName(row); name == "" {
+					res.Add(valid.EmptyName, file, rowNum)
+				}
+			}
+
+			id := a.rowID(row)
+			if id == "" {
+				continue
+			}
+			if _, ok := ids[id]; ok {
+				res.Add(valid.DuplicateID, file, rowNum)
+				continue
+			}
+			ids[id] = rowNum
+			rec := validRec{row: rowNum, id: id, parent: a.parentID(row)}
+			if idx := a.taxon.acceptedNameUsageID; idx != -1 {
+				rec.accepted = a.taxon.field(row, idx)
+			}
+			recs = append(recs, rec)
+		}
+		res.Files[0].RowsNum = rowNum
+		return nil
+	})
+
+	_, err := a.CoreStream(gCtx, ch)
+	_ = g.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		res.AddMessage(valid.ReadError, file, err.Error())
+		return nil, nil
+	}
+
+	validateRefs(res, file, ids, recs)
+	return ids, nil
+}
+
+// validateRefs checks parent and accepted references between Core
+// records and finds cycles in the hierarchy.
+func validateRefs(
+	res *valid.Report,
+	file string,
+	ids map[string]int,
+	recs []validRec,
+) {
+	parents := make(map[string]string)
+	for _, v := range recs {
+		if v.parent != "" {
+			if _, ok := ids[v.parent]; ok {
+				parents[v.id] = v.parent
+			} else {
+				res.Add(valid.BrokenParentID, file, v.row)
+			}
+		}
+		if v.accepted != "" {
+			if _, ok := ids[v.accepted]; !ok {
+				res.Add(valid.BrokenAcceptedID, file, v.row)
+			}
+		}
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int)
+	for _, v := range recs {
+		var path []string
+		pos := make(map[string]int)
+		id := v.id
+		for {
+			if st := state[id]; st != 0 {
+				// a record of the current path is visited again.
+				if st == visiting {
+					for _, cid := range path[pos[id]:] {
+						res.Add(valid.HierarchyCycle, file, ids[cid])
+					}
+				}
+				break
+			}
+			state[id] = visiting
+			pos[id] = len(path)
+			path = append(path, id)
+			parent, ok := parents[id]
+			if !ok {
+				break
+			}
+			id = parent
+		}
+		for _, pid := range path {
+			state[pid] = visited
+		}
+	}
+}
+
+// validateExtension checks rows of an extension. If ids are given, it
+// also checks that coreids of the extension exist in the Core.
+func (a *arch) validateExtension(
+	ctx context.Context,
+	res *valid.Report,
+	index int,
+	ids map[string]int,
+) error {
+	ext := a.meta.Extensions[index]
+	file := ext.Files.Location
+	fileIdx := len(res.Files) - 1
+	maxIdx := ext.CoreID.Idx
+	for _, v := range ext.Fields {
+		maxIdx = max(maxIdx, v.Idx)
+	}
+
+	ch := make(chan []string)
+	g, gCtx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var rowNum int
+		for row := range ch {
+			rowNum++
+			if maxIdx >= len(row) {
+				res.Add(valid.FieldIndex, file, rowNum)
+			}
+			if ids == nil || ext.CoreID.Idx < 0 || ext.CoreID.Idx >= len(row) {
+				continue
+			}
+			if _, ok := ids[a.taxon.field(row, ext.CoreID.Idx)]; !ok {
+				res.Add(valid.OrphanCoreID, file, rowNum)
+			}
+		}
+		res.Files[fileIdx].RowsNum = rowNum
+		return nil
+	})
+
+	_, err := a.ExtensionStream(gCtx, index, ch)
+	_ = g.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		res.AddMessage(valid.ReadError, file, err.Error())
+	}
+	return nil
+}
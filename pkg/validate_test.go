@@ -0,0 +1,156 @@
+package dwca_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/valid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	cfg := config.New()
+	w, err := dwca.NewWriter(cfg)
+	assert.Nil(err)
+
+	err = w.SetCore("http://rs.tdwg.org/dwc/terms/Taxon", []string{
+		"http://rs.tdwg.org/dwc/terms/taxonID",
+		"http://rs.tdwg.org/dwc/terms/scientificName",
+		"http://rs.tdwg.org/dwc/terms/parentNameUsageID",
+		"http://rs.tdwg.org/dwc/terms/acceptedNameUsageID",
+	})
+	assert.Nil(err)
+	idx, err := w.AddExtension(
+		"http://rs.gbif.org/terms/1.0/VernacularName",
+		[]string{
+			"http://rs.tdwg.org/dwc/terms/taxonID",
+			"http://rs.tdwg.org/dwc/terms/vernacularName",
+		},
+	)
+	assert.Nil(err)
+
+	core := [][]string{
+		{"1", "Plantae", "", ""},
+		{"2", "Pinus L.", "1", ""},
+		{"3", "Pinus strobus L.", "2", ""},
+		{"3", "Pinus nigra Arn.", "2", ""},
+		{"4", "", "2", ""},
+		{"5", "Pinus alba", "99", ""},
+		{"6", "Pinus rubra", "2", "98"},
+		{"7", "Abies L.", "8", ""},
+		{"8", "Picea L.", "7", ""},
+	}
+	for _, row := range core {
+		err = w.WriteCoreRow(row)
+		assert.Nil(err)
+	}
+	ext := [][]string{
+		{"3", "eastern white pine"},
+		{"100", "unknown pine"},
+	}
+	for _, row := range ext {
+		err = w.WriteExtensionRow(idx, row)
+		assert.Nil(err)
+	}
+	path := filepath.Join(t.TempDir(), "invalid.zip")
+	err = w.Zip(path)
+	assert.Nil(err)
+	err = w.Close()
+	assert.Nil(err)
+
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	res, err := arc.Validate(context.Background())
+	assert.Nil(err)
+	assert.False(res.Valid)
+	assert.Equal(2, len(res.Files))
+	assert.Equal(9, res.Files[0].RowsNum)
+	assert.Equal(2, res.Files[1].RowsNum)
+
+	issues := make(map[valid.IssueType]valid.Issue)
+	for _, v := range res.Issues {
+		issues[v.Type] = v
+	}
+
+	tests := []struct {
+		msg   string
+		typ   valid.IssueType
+		count int
+		rows  []int
+	}{
+		{"dup", valid.DuplicateID, 1, []int{4}},
+		{"empty", valid.EmptyName, 1, []int{5}},
+		{"parent", valid.BrokenParentID, 1, []int{6}},
+		{"accepted", valid.BrokenAcceptedID, 1, []int{7}},
+		{"cycle", valid.HierarchyCycle, 2, []int{8, 9}},
+		{"orphan", valid.OrphanCoreID, 1, []int{2}},
+	}
+	for _, v := range tests {
+		iss, ok := issues[v.typ]
+		assert.True(ok, v.msg)
+		assert.Equal(v.count, iss.Count, v.msg)
+		assert.Equal(v.rows, iss.Rows, v.msg)
+	}
+	_, ok := issues[valid.MissingFile]
+	assert.False(ok)
+
+	err = arc.Close()
+	assert.Nil(err)
+}
+
+func TestValidateValid(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join("testdata", "diagn", "scinames", "canonical.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	res, err := arc.Validate(context.Background())
+	assert.Nil(err)
+	assert.True(res.Valid)
+	assert.Equal(0, len(res.Issues))
+
+	err = arc.Close()
+	assert.Nil(err)
+}
+
+func TestValidateFiles(t *testing.T) {
+	assert := assert.New(t)
+	// the core has a field beyond the end of rows, and the extension
+	// file is missing.
+	path := filepath.Join("testdata", "broken_files.tar.gz")
+	cfg := config.New()
+	arc, err := dwca.Factory(path, cfg)
+	assert.Nil(err)
+	defer arc.Close()
+	err = arc.Load(cfg.ExtractPath)
+	assert.Nil(err)
+
+	res, err := arc.Validate(context.Background())
+	assert.Nil(err)
+	assert.False(res.Valid)
+
+	issues := make(map[valid.IssueType]valid.Issue)
+	for _, v := range res.Issues {
+		issues[v.Type] = v
+	}
+
+	iss, ok := issues[valid.FieldIndex]
+	assert.True(ok)
+	assert.Equal(2, iss.Count)
+	assert.Equal([]int{1, 2}, iss.Rows)
+
+	iss, ok = issues[valid.MissingFile]
+	assert.True(ok)
+	assert.Equal(1, iss.Count)
+	assert.Equal("vernacular.txt", iss.File)
+}
@@ -0,0 +1,307 @@
+package dwca
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gnames/dwca/internal/ent/dcfile"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/eml"
+	"github.com/gnames/dwca/pkg/ent/meta"
+	"golang.org/x/sync/errgroup"
+)
+
+// writer implements Writer interface.
+type writer struct {
+	// cfg is the configuration object of the writer.
+	cfg config.Config
+
+	// dcFile is the object that handles the DwCA filesystem operations.
+	dcFile dcfile.DCFile
+
+	// meta is the metadata of the created DwCA archive.
+	meta *meta.Meta
+
+	// emlData is the EML data of the created DwCA archive.
+	emlData *eml.EML
+
+	// core is the output stream for the core file.
+	core *writerFile
+
+	// exts are the output streams for the extension files.
+	exts []*writerFile
+
+	// files keeps names of created files to avoid name collisions.
+	files map[string]struct{}
+
+	// done is true when the data files are closed and meta.xml and eml.xml
+	// files are saved.
+	done bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	g      *errgroup.Group
+	mx     sync.RWMutex
+}
+
+// writerFile describes one data file of the created archive.
+type writerFile struct {
+	file      string
+	fieldsNum int
+	ch        chan []string
+}
+
+// newWriter creates a new Writer object.
+func newWriter(cfg config.Config, df dcfile.DCFile) Writer {
+	ctx, cancel := context.WithCancel(context.Background())
+	g, ctx := errgroup.WithContext(ctx)
+	res := &writer{
+		cfg:     cfg,
+		dcFile:  df,
+		emlData: &eml.EML{},
+		files:   make(map[string]struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+		g:       g,
+	}
+	res.meta = &meta.Meta{Archive: meta.Archive{EMLFile: "eml.xml"}}
+	return res
+}
+
+// SetCore sets the row type and the fields of the core file.
+func (w *writer) SetCore(rowType string, fields []string) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.done {
+		return &ErrWriter{Msg: "archive is already created"}
+	}
+	if w.core != nil {
+		return &ErrWriter{Msg: "core is already set"}
+	}
+	if len(fields) == 0 {
+		return &ErrWriter{Msg: "core has no fields"}
+	}
+
+	attr := w.newAttr(rowType, fields)
+	w.meta.Core = &meta.Core{
+		ID:   meta.ID{Index: "0", Idx: 0},
+		Attr: attr,
+	}
+	w.core = w.startFile(attr)
+	return nil
+}
+
+// AddExtension adds an extension with the given row type and fields.
+func (w *writer) AddExtension(rowType string, fields []string) (int, error) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.done {
+		return 0, &ErrWriter{Msg: "archive is already created"}
+	}
+	if len(fields) == 0 {
+		return 0, &ErrWriter{Msg: "extension has no fields"}
+	}
+
+	attr := w.newAttr(rowType, fields)
+	w.meta.Extensions = append(w.meta.Extensions, &meta.Extension{
+		CoreID: meta.CoreID{Index: "0", Idx: 0},
+		Attr:   attr,
+	})
+	w.exts = append(w.exts, w.startFile(attr))
+	return len(w.exts) - 1, nil
+}
+
+// SetEML sets the EML data of the archive.
+func (w *writer) SetEML(e *eml.EML) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if e != nil {
+		w.emlData = e
+	}
+}
+
+// WriteCoreRow adds a row to the core file.
+func (w *writer) WriteCoreRow(row []string) error {
+	w.mx.RLock()
+	defer w.mx.RUnlock()
+
+	if w.done {
+		return &ErrWriter{Msg: "archive is already created"}
+	}
+	if w.core == nil {
+		return &ErrWriter{Msg: "core is not set"}
+	}
+	return w.writeRow(w.core, row)
+}
+
+// WriteExtensionRow adds a row to the extension file with the given index.
+func (w *writer) WriteExtensionRow(index int, row []string) error {
+	w.mx.RLock()
+	defer w.mx.RUnlock()
+
+	if w.done {
+		return &ErrWriter{Msg: "archive is already created"}
+	}
+	if index < 0 || index >= len(w.exts) {
+		return &ErrWriter{Msg: fmt.Sprintf("extension %d is not set", index)}
+	}
+	return w.writeRow(w.exts[index], row)
+}
+
+// Zip creates a ZIP DwCA file at the provided filePath.
+func (w *writer) Zip(filePath string) error {
+	err := w.finish()
+	if err != nil {
+		return err
+	}
+	slog.Info("Creating zip archive", "output", filePath)
+	return w.dcFile.Zip(w.cfg.OutputPath, filePath)
+}
+
+// TarGz creates a TAR.GZ DwCA file at the provided filePath.
+func (w *writer) TarGz(filePath string) error {
+	err := w.finish()
+	if err != nil {
+		return err
+	}
+	slog.Info("Creating tar.gz archive", "output", filePath)
+	return w.dcFile.TarGz(w.cfg.OutputPath, filePath)
+}
+
+// Close stops writing and removes temporary files.
+func (w *writer) Close() error {
+	w.mx.Lock()
+	if !w.done {
+		w.cancel()
+		w.closeFiles()
+		_ = w.g.Wait()
+		w.done = true
+	}
+	w.mx.Unlock()
+	return w.dcFile.Close()
+}
+
+func (w *writer) writeRow(wf *writerFile, row []string) error {
+	if len(row) != wf.fieldsNum {
+		return &ErrRowSize{
+			File:         wf.file,
+			FieldsNum:    wf.fieldsNum,
+			RowFieldsNum: len(row),
+		}
+	}
+
+	select {
+	case <-w.ctx.Done():
+		return &dcfile.ErrContext{Err: w.ctx.Err()}
+	case wf.ch <- row:
+	}
+	return nil
+}
+
+// finish closes data streams and saves meta.xml and eml.xml files.
+func (w *writer) finish() error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.done {
+		return nil
+	}
+	if w.core == nil {
+		return &ErrWriter{Msg: "core is not set"}
+	}
+
+	w.closeFiles()
+	w.done = true
+	err := w.g.Wait()
+	if err != nil {
+		return err
+	}
+
+	bs, err := w.meta.Bytes()
+	if err != nil {
+		return err
+	}
+	err = w.dcFile.SaveToFile("meta.xml", bs)
+	if err != nil {
+		return err
+	}
+
+	bs, err = w.emlData.Bytes()
+	if err != nil {
+		return err
+	}
+	return w.dcFile.SaveToFile("eml.xml", bs)
+}
+
+func (w *writer) closeFiles() {
+	if w.core != nil {
+		close(w.core.ch)
+	}
+	for _, v := range w.exts {
+		close(v.ch)
+	}
+}
+
+// startFile starts a goroutine that saves incoming rows to a CSV file.
+func (w *writer) startFile(attr *meta.Attr) *writerFile {
+	res := &writerFile{
+		file:      attr.Files.Location,
+		fieldsNum: len(attr.Fields),
+		ch:        make(chan []string),
+	}
+	headers := meta.Headers(0, attr.Fields)
+	delim := attr.FieldsTerminatedBy
+	w.g.Go(func() error {
+		return w.dcFile.ExportCSVStream(w.ctx, res.file, headers, delim, res.ch)
+	})
+	return res
+}
+
+func (w *writer) newAttr(rowType string, fields []string) *meta.Attr {
+	delim := ","
+	if w.cfg.OutputCSVType == "tsv" {
+		delim = `\t`
+	}
+
+	res := &meta.Attr{
+		Encoding:           "UTF-8",
+		FieldsTerminatedBy: delim,
+		LinesTerminatedBy:  `\n`,
+		FieldsEnclosedBy:   `"`,
+		IgnoreHeaderLines:  "1",
+		RowType:            rowType,
+		Files:              meta.Files{Location: w.fileName(rowType)},
+	}
+	for i, v := range fields {
+		res.Fields = append(res.Fields, meta.Field{
+			Index: strconv.Itoa(i),
+			Idx:   i,
+			Term:  v,
+		})
+	}
+	return res
+}
+
+// fileName creates a unique name for a data file from its row type.
+func (w *writer) fileName(rowType string) string {
+	name := strings.ToLower(filepath.Base(rowType))
+	if name == "" || name == "." || name == "/" {
+		name = "data"
+	}
+	res := name + ".txt"
+	for i := 1; ; i++ {
+		if _, ok := w.files[res]; !ok {
+			break
+		}
+		res = name + strconv.Itoa(i) + ".txt"
+	}
+	w.files[res] = struct{}{}
+	return res
+}
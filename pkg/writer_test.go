@@ -0,0 +1,100 @@
+package dwca_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	dwca "github.com/gnames/dwca/pkg"
+	"github.com/gnames/dwca/pkg/config"
+	"github.com/gnames/dwca/pkg/ent/eml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		msg, file, csvType string
+	}{
+		{"zip", "test.zip", "csv"},
+		{"tar", "test.tar.gz", "tsv"},
+	}
+
+	for _, v := range tests {
+		cfg := config.New(config.OptOutputCSVType(v.csvType))
+		w, err := dwca.NewWriter(cfg)
+		assert.Nil(err, v.msg)
+
+		err = w.WriteCoreRow([]string{"1"})
+		assert.NotNil(err, v.msg)
+
+		err = w.SetCore("http://rs.tdwg.org/dwc/terms/Taxon", []string{
+			"http://rs.tdwg.org/dwc/terms/taxonID",
+			"http://rs.tdwg.org/dwc/terms/scientificName",
+			"http://rs.tdwg.org/dwc/terms/parentNameUsageID",
+		})
+		assert.Nil(err, v.msg)
+
+		idx, err := w.AddExtension(
+			"http://rs.gbif.org/terms/1.0/VernacularName",
+			[]string{
+				"http://rs.tdwg.org/dwc/terms/taxonID",
+				"http://rs.tdwg.org/dwc/terms/vernacularName",
+			},
+		)
+		assert.Nil(err, v.msg)
+		assert.Equal(0, idx, v.msg)
+
+		w.SetEML(&eml.EML{Dataset: eml.Dataset{Title: "Test, writer"}})
+
+		core := [][]string{
+			{"1", "Plantae", ""},
+			{"2", "Pinus L.", "1"},
+			{"3", "Pinus strobus L.", "2"},
+		}
+		for _, row := range core {
+			err = w.WriteCoreRow(row)
+			assert.Nil(err, v.msg)
+		}
+		err = w.WriteCoreRow([]string{"4", "Pinus"})
+		assert.NotNil(err, v.msg)
+		_, ok := err.(*dwca.ErrRowSize)
+		assert.True(ok, v.msg)
+
+		err = w.WriteExtensionRow(idx, []string{"3", "eastern white pine"})
+		assert.Nil(err, v.msg)
+		err = w.WriteExtensionRow(5, []string{"3", "white pine"})
+		assert.NotNil(err, v.msg)
+
+		path := filepath.Join(t.TempDir(), v.file)
+		if v.msg == "zip" {
+			err = w.Zip(path)
+		} else {
+			err = w.TarGz(path)
+		}
+		assert.Nil(err, v.msg)
+
+		err = w.WriteCoreRow([]string{"5", "Pinus nigra", "2"})
+		assert.NotNil(err, v.msg)
+
+		arc, err := dwca.Factory(path, cfg)
+		assert.Nil(err, v.msg)
+		err = arc.Load(cfg.ExtractPath)
+		assert.Nil(err, v.msg)
+
+		assert.Equal("Test, writer", arc.EML().Dataset.Title, v.msg)
+		assert.Equal(1, len(arc.Meta().Extensions), v.msg)
+
+		data, err := arc.CoreSlice(0, 0)
+		assert.Nil(err, v.msg)
+		assert.Equal(core, data, v.msg)
+
+		ext, err := arc.ExtensionSlice(0, 0, 0)
+		assert.Nil(err, v.msg)
+		assert.Equal([][]string{{"3", "eastern white pine"}}, ext, v.msg)
+
+		err = arc.Close()
+		assert.Nil(err, v.msg)
+		err = w.Close()
+		assert.Nil(err, v.msg)
+	}
+}